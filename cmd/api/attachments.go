@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/blobstore"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// multipartOverhead is the room allowed on top of the attachment size limit for
+// multipart boundaries, part headers and the small form fields.
+const multipartOverhead = 64 << 10
+
+// attachmentTransferTimeout replaces the server-wide read and write timeouts for
+// uploads and downloads, which are too short for large files.
+const attachmentTransferTimeout = 10 * time.Minute
+
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+func (app *application) getModuleAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	_, err = app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	attachments, err := app.models.Attachments.GetForModule(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"attachments": attachments}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadModuleAttachmentHandler accepts a multipart/form-data body with a "file" part
+// and an optional "kind" field. The file is streamed straight into the blob store
+// while its checksum is computed, so it is never held in memory.
+func (app *application) uploadModuleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	moduleInfo, err := app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	maxBytes := app.config.attachments.maxBytes
+	app.extendDeadlines(w, attachmentTransferTimeout)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	attachment := &data.Attachment{
+		ModuleID:   moduleInfo.ID,
+		Kind:       "other",
+		UploadedBy: app.contextGetUser(r).ID,
+	}
+	v := validator.New()
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			app.cleanupAttachment(attachment)
+			app.multipartErrorResponse(w, r, err, maxBytes)
+			return
+		}
+		switch part.FormName() {
+		case "kind":
+			kind, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				app.cleanupAttachment(attachment)
+				app.multipartErrorResponse(w, r, err, maxBytes)
+				return
+			}
+			attachment.Kind = strings.TrimSpace(string(kind))
+		case "file":
+			if attachment.StorageKey != "" {
+				app.cleanupAttachment(attachment)
+				app.badRequestResponse(w, r, errors.New("body must contain a single file"))
+				return
+			}
+			err = app.storeAttachment(r, part, attachment, maxBytes, v)
+			if err != nil {
+				app.cleanupAttachment(attachment)
+				app.multipartErrorResponse(w, r, err, maxBytes)
+				return
+			}
+			if !v.Valid() {
+				app.failedValidationResponse(w, r, v.Errors)
+				return
+			}
+		default:
+			_, err = io.Copy(io.Discard, part)
+			if err != nil {
+				app.cleanupAttachment(attachment)
+				app.multipartErrorResponse(w, r, err, maxBytes)
+				return
+			}
+		}
+	}
+	if attachment.StorageKey == "" {
+		v.AddError("file", "must be provided")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if data.ValidateAttachment(v, attachment); !v.Valid() {
+		app.cleanupAttachment(attachment)
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Attachments.Insert(attachment)
+	if err != nil {
+		app.cleanupAttachment(attachment)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/moduleinfo/%d/attachments/%d", moduleInfo.ID, attachment.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"attachment": attachment}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// storeAttachment sniffs the content type from the first bytes of the part and, if
+// the type is allowed, streams the part into the blob store. A rejected type is
+// reported through v and nothing is stored.
+func (app *application) storeAttachment(r *http.Request, part *multipart.Part, attachment *data.Attachment, maxBytes int64, v *validator.Validator) error {
+	// Keep only the base name; some clients send the full path of the local file.
+	fileName := filepath.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+	if fileName == "." || fileName == "/" {
+		fileName = ""
+	}
+	attachment.FileName = fileName
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+	attachment.ContentType = http.DetectContentType(head)
+	if data.ValidateAttachmentContentType(v, attachment.ContentType); !v.Valid() {
+		return nil
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	src := io.TeeReader(io.MultiReader(bytes.NewReader(head), part), hash)
+	attachment.StorageKey = key
+	// Read one byte past the limit so an oversized file can be told apart from one
+	// that is exactly maxBytes long.
+	size, err := app.blobs.Put(r.Context(), key, io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if size > maxBytes {
+		return errAttachmentTooLarge
+	}
+	attachment.Size = size
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// cleanupAttachment removes a blob that was stored for an upload that did not make it
+// into the database.
+func (app *application) cleanupAttachment(attachment *data.Attachment) {
+	if attachment.StorageKey == "" {
+		return
+	}
+	err := app.blobs.Delete(context.Background(), attachment.StorageKey)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"storage_key": attachment.StorageKey})
+	}
+	attachment.StorageKey = ""
+}
+func (app *application) multipartErrorResponse(w http.ResponseWriter, r *http.Request, err error, maxBytes int64) {
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.Is(err, errAttachmentTooLarge), errors.As(err, &maxBytesError):
+		app.payloadTooLargeResponse(w, r, maxBytes)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		app.badRequestResponse(w, r, errors.New("body contains a truncated multipart form"))
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func newStorageKey() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+func (app *application) getModuleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachment(w, r)
+	if !ok {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"attachment": attachment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// downloadModuleAttachmentHandler streams the attachment contents. http.ServeContent
+// takes care of Range, If-Range and conditional requests against the checksum ETag.
+func (app *application) downloadModuleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachment(w, r)
+	if !ok {
+		return
+	}
+	blob, err := app.blobs.Open(r.Context(), attachment.StorageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, blobstore.ErrNotFound):
+			app.logError(r, fmt.Errorf("attachment %d has no blob %q", attachment.ID, attachment.StorageKey))
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+
+	app.extendDeadlines(w, attachmentTransferTimeout)
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+attachment.Checksum+`"`)
+	http.ServeContent(w, r, attachment.FileName, attachment.CreatedAt, blob)
+}
+func (app *application) deleteModuleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachment(w, r)
+	if !ok {
+		return
+	}
+	key, err := app.models.Attachments.Delete(attachment.ModuleID, attachment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// The row is gone, so a blob left behind by a failed delete is only wasted space;
+	// log it rather than failing the request.
+	err = app.blobs.Delete(r.Context(), key)
+	if err != nil {
+		app.logError(r, err)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "attachment successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAttachment loads the attachment named by the route, writing the error response
+// itself when it returns false. Attachments of modules in the trash are not served.
+func (app *application) readAttachment(w http.ResponseWriter, r *http.Request) (*data.Attachment, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	attachmentID, err := app.readNamedIDParam(r, "attachment_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	_, err = app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	attachment, err := app.models.Attachments.Get(id, attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return attachment, true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// versionETag is the strong entity tag of a record at the given version. A tag only
+// has to tell representations of the same URL apart, so the version is enough.
+func versionETag(version int32) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// etagListed reports whether etag appears in header, an If-Match or If-None-Match
+// value. If-None-Match uses the weak comparison, which ignores the W/ prefix;
+// If-Match uses the strong one, which never matches a weak tag.
+func etagListed(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header for a record at the given version. If the
+// request's If-None-Match lists that tag it also sends 304 Not Modified and
+// returns true, and the handler has nothing more to write.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, version int32) bool {
+	etag := versionETag(version)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagListed(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch checks the request's If-Match header against the record's current
+// version. On a mismatch it sends 412 Precondition Failed and returns false.
+// Requests without the header always pass.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, version int32) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagListed(header, versionETag(version), false) {
+		return true
+	}
+	app.preconditionFailedResponse(w, r)
+	return false
+}
+
+// versionConflictResponse reports a version conflict detected while saving. A client
+// that sent If-Match asked for the write to be conditional, so it gets 412 rather
+// than 409.
+func (app *application) versionConflictResponse(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("If-Match") != "" {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+	app.editConflicResponse(w, r)
+}
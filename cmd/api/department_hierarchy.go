@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+func (app *application) getDepSubtreeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	subtree, err := app.models.DepartmentInfoModel.GetSubtree(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"subtree": subtree}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getDepAncestorsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	ancestors, err := app.models.DepartmentInfoModel.GetAncestors(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"ancestors": ancestors}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// moveDepHandler re-parents a unit. A null or zero parent_id makes it a top level
+// unit.
+func (app *application) moveDepHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	departmentInfo, err := app.models.DepartmentInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		ParentID *int64 `json:"parent_id"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var parentID int64
+	if input.ParentID != nil {
+		parentID = *input.ParentID
+	}
+	v := validator.New()
+	v.Check(parentID >= 0, "parent_id", "must be positive number")
+	v.Check(parentID != id, "parent_id", "must not reference the unit itself")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.DepartmentInfoModel.Move(departmentInfo, parentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("parent_id", "must reference an existing unit")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrHierarchyLoop):
+			v.AddError("parent_id", "must not be the unit itself or one of its descendants")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated department info": departmentInfo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
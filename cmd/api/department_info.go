@@ -10,30 +10,51 @@ import (
 
 func (app *application) CreateDepInfoHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		DepartmentName     string `json:"department_name"`
-		StaffQuantity      int32  `json:"staff_quantity"`
-		DepartmentDirector string `json:"department_director"`
-		ModuleID           int64  `json:"module_id"`
+		DepartmentName string `json:"department_name"`
+		DirectorID     int64  `json:"director_id"`
+		ParentID       int64  `json:"parent_id"`
+		UnitType       string `json:"unit_type"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 	v := validator.New()
 
+	if input.UnitType == "" {
+		input.UnitType = "department"
+	}
 	departmentInfo := &data.DepartmentInfo{
-		DepartmentName:     input.DepartmentName,
-		StaffQuantity:      input.StaffQuantity,
-		DepartmentDirector: input.DepartmentDirector,
-		ModuleID:           input.ModuleID,
+		DepartmentName: input.DepartmentName,
+		DirectorID:     input.DirectorID,
+		ParentID:       input.ParentID,
+		UnitType:       input.UnitType,
 	}
+	v.Check(departmentInfo.DirectorID != 0, "director_id", "must be provided")
 	if data.ValidateDepartmentInfo(v, departmentInfo); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.DepartmentInfoModel.Insert(departmentInfo)
+	err = app.checkDirector(v, departmentInfo.DirectorID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.DepartmentInfoModel.Insert(departmentInfo)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("parent_id", "must reference an existing unit")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/departmentinfo/%d", departmentInfo.ID))
@@ -47,6 +68,15 @@ func (app *application) GetDepInfoHandler(w http.ResponseWriter, r *http.Request
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	fields := app.readFields(qs, &data.DepartmentInfo{}, v)
+	includes := app.readIncludes(qs, v, departmentInfoIncludes...)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 	departmentInfo, err := app.models.DepartmentInfoModel.Get(id)
 	if err != nil {
@@ -59,9 +89,239 @@ func (app *application) GetDepInfoHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+	shaped, err := app.shapeDepartmentInfos(r, []*data.DepartmentInfo{departmentInfo}, fields, includes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"department info": shaped[0]}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// departmentInfoSortSafeList is shared by the list and export handlers.
+var departmentInfoSortSafeList = []string{"department_name", "-department_name", "staff_quantity", "-staff_quantity", "director_id", "-director_id", "parent_id", "-parent_id", "unit_type", "-unit_type", "id", "-id"}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"department info": departmentInfo}, nil)
+// departmentInfoIncludes are the related resources ?include= can embed in a unit.
+var departmentInfoIncludes = []string{"modules", "staff"}
+
+// shapeDepartmentInfos applies ?fields= and ?include= to departmentInfos. Related
+// records are loaded for all of them with one query per include. Staff email
+// addresses are only embedded for callers allowed to see them.
+func (app *application) shapeDepartmentInfos(r *http.Request, departmentInfos []*data.DepartmentInfo, fields, includes []string) ([]any, error) {
+	ids := make([]int64, len(departmentInfos))
+	for i, departmentInfo := range departmentInfos {
+		ids[i] = departmentInfo.ID
+	}
+	var moduleInfos map[int64][]*data.ModuleInfo
+	var staff map[int64][]*data.StaffMember
+	var err error
+	if included(includes, "modules") {
+		moduleInfos, err = app.models.DepartmentModules.GetModulesForDepartments(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if included(includes, "staff") {
+		staff, err = app.models.DepartmentStaff.GetForDepartments(ids)
+		if err != nil {
+			return nil, err
+		}
+		showEmails, err := app.canSeeEmails(r)
+		if err != nil {
+			return nil, err
+		}
+		if !showEmails {
+			for _, members := range staff {
+				for _, member := range members {
+					member.Email = ""
+				}
+			}
+		}
+	}
+	shaped := make([]any, len(departmentInfos))
+	for i, departmentInfo := range departmentInfos {
+		embedded := make(map[string]any)
+		if moduleInfos != nil {
+			modules := moduleInfos[departmentInfo.ID]
+			if modules == nil {
+				modules = []*data.ModuleInfo{}
+			}
+			embedded["modules"] = modules
+		}
+		if staff != nil {
+			members := staff[departmentInfo.ID]
+			if members == nil {
+				members = []*data.StaffMember{}
+			}
+			embedded["staff"] = members
+		}
+		shaped[i], err = shape(departmentInfo, fields, embedded)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return shaped, nil
+}
+
+func (app *application) GetAllDepInfosHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		DepartmentName string
+		DirectorName   string
+		Within         int64
+		Filters        data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.DepartmentName = app.readString(qs, "departmentname", "")
+	input.DirectorName = app.readString(qs, "departmentdirector", "")
+	input.Within = int64(app.readInt(qs, "within", 0, v))
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = departmentInfoSortSafeList
+	input.Filters.Filter = app.readString(qs, "filter", "")
+	input.Filters.FilterFields = data.DepartmentInfoFilterFields
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.SkipCount = !app.readBool(qs, "count", input.Filters.Cursor == "", v)
+	fields := app.readFields(qs, &data.DepartmentInfo{}, v)
+	includes := app.readIncludes(qs, v, departmentInfoIncludes...)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	departmentInfos, metadata, err := app.models.DepartmentInfoModel.GetAll(input.DepartmentName, input.DirectorName, input.Within, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "is invalid")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	shaped, err := app.shapeDepartmentInfos(r, departmentInfos, fields, includes)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"department infos": shaped, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) EditDepInfoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	departmentInfo, err := app.models.DepartmentInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		DepartmentName *string `json:"department_name"`
+		DirectorID     *int64  `json:"director_id"`
+		UnitType       *string `json:"unit_type"`
+	}
+	err = app.readPatch(w, r, departmentInfo, &input)
+	if err != nil {
+		app.patchErrorResponse(w, r, err)
+		return
+	}
+	if input.DepartmentName != nil {
+		departmentInfo.DepartmentName = *input.DepartmentName
+	}
+	if input.DirectorID != nil {
+		departmentInfo.DirectorID = *input.DirectorID
+	}
+	if input.UnitType != nil {
+		departmentInfo.UnitType = *input.UnitType
+	}
+	v := validator.New()
+	if input.DirectorID != nil {
+		v.Check(departmentInfo.DirectorID != 0, "director_id", "must be provided")
+	}
+	if data.ValidateDepartmentInfo(v, departmentInfo); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if input.DirectorID != nil {
+		err = app.checkDirector(v, departmentInfo.DirectorID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+	err = app.models.DepartmentInfoModel.Update(departmentInfo)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated department info": departmentInfo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) DeleteDepInfoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.DepartmentInfoModel.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrUnitHasChildren):
+			v := validator.New()
+			v.AddError("id", "unit still has nested units; move or delete them first")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "department info successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkDirector adds a validation error unless the director is an existing, activated
+// user.
+func (app *application) checkDirector(v *validator.Validator, directorID int64) error {
+	director, err := app.models.UserInfoModel.Get(directorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("director_id", "must reference an existing user")
+			return nil
+		default:
+			return err
+		}
 	}
+	v.Check(director.Activated, "director_id", "must reference an activated user")
+	return nil
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+func (app *application) getDepartmentStaffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		StaffRole string
+		Filters   data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.StaffRole = app.readString(qs, "staff_role", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "sname")
+	input.Filters.SortSafeList = []string{"fname", "-fname", "sname", "-sname", "staff_role", "-staff_role"}
+
+	if input.StaffRole != "" {
+		v.Check(validator.PermittedValue(input.StaffRole, data.StaffRoles...), "staff_role", "invalid staff role")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	_, err = app.models.DepartmentInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	members, metadata, err := app.models.DepartmentStaff.GetAll(id, input.StaffRole, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !showEmails {
+		for _, member := range members {
+			member.Email = ""
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"staff": members, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) addDepartmentStaffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		UserID    int64  `json:"user_id"`
+		StaffRole string `json:"staff_role"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	member := &data.StaffMember{
+		UserID:    input.UserID,
+		StaffRole: input.StaffRole,
+	}
+	v := validator.New()
+	if data.ValidateStaffMember(v, member); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	_, err = app.models.DepartmentInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	user, err := app.models.UserInfoModel.Get(member.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", "must reference an existing user")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	member.Name = user.Name
+	member.Surname = user.Surname
+	member.Email = user.Email
+	err = app.models.DepartmentStaff.Add(id, member)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateStaff):
+			v.AddError("user_id", "is already a staff member of this department")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !showEmails {
+		member.Email = ""
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"staff member": member}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) removeDepartmentStaffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	userID, err := app.readNamedIDParam(r, "user_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.DepartmentStaff.Remove(id, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "staff member successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+func (app *application) enrollHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// The user_id is optional and defaults to the authenticated user, so students can
+	// sign themselves up with an empty object while staff can enroll someone else.
+	var input struct {
+		UserID *int64 `json:"user_id"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	userID := app.contextGetUser(r).ID
+	if input.UserID != nil {
+		userID = *input.UserID
+	}
+	v := validator.New()
+	if v.Check(userID > 0, "user_id", "must be positive number"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	_, err = app.models.UserInfoModel.Get(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", "must reference an existing user")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	enrollment, err := app.models.Enrollments.Enroll(userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrAlreadyEnrolled):
+			v.AddError("user_id", "is already enrolled or waitlisted for this module")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"enrollment": enrollment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) dropEnrollmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	userID, err := app.readNamedIDParam(r, "user_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	promoted, err := app.models.Enrollments.Drop(userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if len(promoted) > 0 {
+		moduleInfo, err := app.models.ModuleInfoModel.GetIncludingDeleted(id)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.notifyPromoted(moduleInfo, promoted)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "enrollment successfully dropped", "promoted": promoted}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getModuleEnrollmentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	_, err = app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	enrollments, err := app.models.Enrollments.GetForModule(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"enrollments": enrollments}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getUserEnrollmentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	enrollments, err := app.models.Enrollments.GetForUser(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"enrollments": enrollments}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyPromoted emails every student who was moved off the waitlist of a module.
+func (app *application) notifyPromoted(moduleInfo *data.ModuleInfo, promoted []*data.Enrollment) {
+	for _, enrollment := range promoted {
+		userID := enrollment.UserID
+		app.background(func() {
+			user, err := app.models.UserInfoModel.Get(userID)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				return
+			}
+			data := map[string]any{
+				"moduleName": moduleInfo.ModuleName,
+			}
+			err = app.mailer.Send(user.Email, "enrollment_promoted.tmpl", data)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+// gradeResult fills in the letter grade and GPA points of a result using the grading
+// scale for its module's exam type, which the model reads along with the result.
+func (app *application) gradeResult(result *data.ExamResult) error {
+	scales, err := app.models.GradingScales.GetAll()
+	if err != nil {
+		return err
+	}
+	result.Letter, result.GPAPoints = scales.For(result.ExamType).Grade(result.Score)
+	return nil
+}
+func (app *application) createExamResultHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID   int64   `json:"user_id"`
+		ModuleID int64   `json:"module_id"`
+		Score    float64 `json:"score"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	result := &data.ExamResult{
+		UserID:     input.UserID,
+		ModuleID:   input.ModuleID,
+		Score:      input.Score,
+		RecordedBy: app.contextGetUser(r).ID,
+	}
+	v := validator.New()
+	if data.ValidateExamResult(v, result); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ExamResults.Insert(result)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateResult):
+			v.AddError("module_id", "a result for this student and module already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", "user_id and module_id must reference existing records")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.gradeResult(result)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/results/%d", result.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"result": result}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getExamResultHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	result, err := app.models.ExamResults.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.gradeResult(result)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"result": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) editExamResultHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	result, err := app.models.ExamResults.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Score *float64 `json:"score"`
+	}
+	err = app.readPatch(w, r, result, &input)
+	if err != nil {
+		app.patchErrorResponse(w, r, err)
+		return
+	}
+	if input.Score != nil {
+		result.Score = *input.Score
+	}
+	result.RecordedBy = app.contextGetUser(r).ID
+	v := validator.New()
+	if data.ValidateExamResult(v, result); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ExamResults.Update(result)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.gradeResult(result)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated result": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) deleteExamResultHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.ExamResults.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "result successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getTranscriptHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	_, err = app.models.UserInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	scales, err := app.models.GradingScales.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	transcript, err := app.models.ExamResults.GetTranscript(id, scales)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"transcript": transcript}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getGradeDistributionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	moduleInfo, err := app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	scales, err := app.models.GradingScales.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	distribution, err := app.models.ExamResults.GetDistribution(id, scales.For(moduleInfo.ExamType))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"grade distribution": distribution}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getGradingScalesHandler(w http.ResponseWriter, r *http.Request) {
+	scales, err := app.models.GradingScales.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"grading scales": scales}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) replaceGradingScaleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ExamType string            `json:"exam_type"`
+		Bands    data.GradingScale `json:"bands"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateGradingScale(v, input.Bands); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// An empty exam type replaces the default scale.
+	if input.ExamType != "" {
+		err = app.checkExamType(v, "exam_type", input.ExamType)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+	err = app.models.GradingScales.Replace(input.ExamType, input.Bands)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	scales, err := app.models.GradingScales.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"grading scale": scales.For(input.ExamType)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
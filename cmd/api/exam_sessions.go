@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+	"time"
+)
+
+// checkExamSession looks for students who would sit two exams at once. In strict mode
+// any such clash is added to the validator as an error; otherwise the clashes are
+// returned so they can be sent back as warnings.
+func (app *application) checkExamSession(v *validator.Validator, session *data.ExamSession, strict bool) ([]data.StudentConflict, error) {
+	conflicts, err := app.models.ExamSessions.StudentConflicts(session)
+	if err != nil {
+		return nil, err
+	}
+	if strict && len(conflicts) > 0 {
+		v.AddError("starts_at", fmt.Sprintf("%d enrolled students have another exam at an overlapping time", len(conflicts)))
+	}
+	return conflicts, nil
+}
+func (app *application) createExamSessionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ModuleID int64     `json:"module_id"`
+		Room     string    `json:"room"`
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	strict := app.readBool(r.URL.Query(), "strict", false, v)
+	session := &data.ExamSession{
+		ModuleID: input.ModuleID,
+		Room:     input.Room,
+		StartsAt: input.StartsAt,
+		EndsAt:   input.EndsAt,
+	}
+	if data.ValidateExamSession(v, session); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	conflicts, err := app.checkExamSession(v, session, strict)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ExamSessions.Insert(session)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRoomDoubleBooked):
+			v.AddError("room", "is already booked for an overlapping session")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("module_id", "must reference an existing module")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/exams/%d", session.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"exam": session, "warnings": conflicts}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getExamSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	session, err := app.models.ExamSessions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"exam": session}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getAllExamSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		From     time.Time
+		To       time.Time
+		ModuleID int
+		Room     string
+		Filters  data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.From = app.readTime(qs, "from", v)
+	input.To = app.readTime(qs, "to", v)
+	input.ModuleID = app.readInt(qs, "module_id", 0, v)
+	input.Room = app.readString(qs, "room", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "starts_at")
+	input.Filters.SortSafeList = []string{"starts_at", "-starts_at", "room", "-room", "module_id", "-module_id", "id", "-id"}
+
+	if !input.From.IsZero() && !input.To.IsZero() {
+		v.Check(input.To.After(input.From), "to", "must be after from")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	sessions, metadata, err := app.models.ExamSessions.GetAll(input.From, input.To, int64(input.ModuleID), input.Room, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"exams": sessions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) editExamSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	session, err := app.models.ExamSessions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		ModuleID *int64     `json:"module_id"`
+		Room     *string    `json:"room"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+	}
+	err = app.readPatch(w, r, session, &input)
+	if err != nil {
+		app.patchErrorResponse(w, r, err)
+		return
+	}
+	if input.ModuleID != nil {
+		session.ModuleID = *input.ModuleID
+	}
+	if input.Room != nil {
+		session.Room = *input.Room
+	}
+	if input.StartsAt != nil {
+		session.StartsAt = *input.StartsAt
+	}
+	if input.EndsAt != nil {
+		session.EndsAt = *input.EndsAt
+	}
+	v := validator.New()
+	strict := app.readBool(r.URL.Query(), "strict", false, v)
+	if data.ValidateExamSession(v, session); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	conflicts, err := app.checkExamSession(v, session, strict)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ExamSessions.Update(session)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		case errors.Is(err, data.ErrRoomDoubleBooked):
+			v.AddError("room", "is already booked for an overlapping session")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("module_id", "must reference an existing module")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated exam": session, "warnings": conflicts}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) deleteExamSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.ExamSessions.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "exam successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
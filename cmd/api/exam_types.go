@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+func (app *application) getAllExamTypesHandler(w http.ResponseWriter, r *http.Request) {
+	examTypes, err := app.models.ExamTypes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"exam types": examTypes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getExamTypeHandler(w http.ResponseWriter, r *http.Request) {
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+	examType, err := app.models.ExamTypes.Get(code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"exam type": examType}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) createExamTypeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code        string   `json:"code"`
+		DisplayName string   `json:"display_name"`
+		Weighting   *float64 `json:"weighting"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	examType := &data.ExamType{
+		Code:        input.Code,
+		DisplayName: input.DisplayName,
+		Weighting:   100,
+	}
+	if input.Weighting != nil {
+		examType.Weighting = *input.Weighting
+	}
+	v := validator.New()
+	if data.ValidateExamType(v, examType); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ExamTypes.Insert(examType)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateExamType):
+			v.AddError("code", "an exam type with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/examtypes/%s", examType.Code))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"exam type": examType}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) editExamTypeHandler(w http.ResponseWriter, r *http.Request) {
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+	examType, err := app.models.ExamTypes.Get(code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		DisplayName *string  `json:"display_name"`
+		Weighting   *float64 `json:"weighting"`
+	}
+	err = app.readPatch(w, r, examType, &input)
+	if err != nil {
+		app.patchErrorResponse(w, r, err)
+		return
+	}
+	if input.DisplayName != nil {
+		examType.DisplayName = *input.DisplayName
+	}
+	if input.Weighting != nil {
+		examType.Weighting = *input.Weighting
+	}
+	v := validator.New()
+	if data.ValidateExamType(v, examType); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ExamTypes.Update(examType)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated exam type": examType}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) deleteExamTypeHandler(w http.ResponseWriter, r *http.Request) {
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+	err := app.models.ExamTypes.Delete(code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrExamTypeInUse):
+			v := validator.New()
+			v.AddError("code", "is still used by one or more modules")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "exam type successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkExamType adds a validation error under key unless code is in the exam type
+// catalogue.
+func (app *application) checkExamType(v *validator.Validator, key string, code string) error {
+	_, err := app.models.ExamTypes.Get(code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError(key, "must reference a known exam type code")
+			return nil
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	// exportTimeout replaces the server-wide write timeout for exports, which send
+	// every matching row rather than a single page.
+	exportTimeout = 10 * time.Minute
+	// exportFlushRows is how many rows are written between flushes, so the client
+	// starts receiving data straight away instead of when the export ends.
+	exportFlushRows = 500
+)
+
+var exportFormats = []string{exportFormatCSV, exportFormatNDJSON}
+
+// exporter writes export rows to the response as CSV, with columns as the header
+// row, or as one JSON object per line. Nothing is sent until the first row (or
+// finish), so an export whose query fails up front still gets a proper error
+// response.
+type exporter struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	format   string
+	filename string
+	columns  []string
+	csv      *csv.Writer
+	json     *json.Encoder
+	started  bool
+	rows     int
+}
+
+func (app *application) newExporter(w http.ResponseWriter, format, name string, columns []string) *exporter {
+	app.extendDeadlines(w, exportTimeout)
+	return &exporter{
+		w:        w,
+		rc:       http.NewResponseController(w),
+		format:   format,
+		filename: fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), format),
+		columns:  columns,
+	}
+}
+
+func (e *exporter) start() error {
+	e.started = true
+	switch e.format {
+	case exportFormatNDJSON:
+		e.w.Header().Set("Content-Type", "application/x-ndjson")
+		e.json = json.NewEncoder(e.w)
+	default:
+		e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		e.csv = csv.NewWriter(e.w)
+	}
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+	e.w.Header().Set("Cache-Control", "no-store")
+	e.w.WriteHeader(http.StatusOK)
+	if e.csv != nil {
+		return e.csv.Write(e.columns)
+	}
+	return nil
+}
+
+// write sends one row: record for NDJSON, fields (in column order) for CSV.
+func (e *exporter) write(record any, fields []string) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(fields)
+	} else {
+		err = e.json.Encode(record)
+	}
+	if err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return e.rc.Flush()
+}
+
+// finishExport completes the response once the model has stopped calling back. An
+// error before the first row is reported normally; after that the status line has
+// gone, so the connection is aborted instead to stop the client mistaking a
+// truncated export for a complete one.
+func (app *application) finishExport(r *http.Request, w http.ResponseWriter, e *exporter, err error) {
+	if err == nil && !e.started {
+		err = e.start()
+	}
+	if err == nil {
+		err = e.flush()
+	}
+	if err == nil {
+		return
+	}
+	if !e.started {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.logger.PrintError(err, map[string]string{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+		"rows_written":   strconv.Itoa(e.rows),
+	})
+	panic(http.ErrAbortHandler)
+}
+
+// readExportFormat reads the format query string value, defaulting to CSV.
+func (app *application) readExportFormat(r *http.Request, v *validator.Validator) string {
+	format := strings.ToLower(app.readString(r.URL.Query(), "format", exportFormatCSV))
+	v.Check(validator.PermittedValue(format, exportFormats...), "format", "must be csv or ndjson")
+	return format
+}
+
+// csvText guards a free-text cell against being read as a formula by spreadsheet
+// applications.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (app *application) exportModuleInfosHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ModuleName string
+		ExamType   string
+		Within     int64
+		Format     string
+		Filters    data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.ModuleName = app.readString(qs, "modulename", "")
+	input.ExamType = app.readString(qs, "examtype", "")
+	input.Within = int64(app.readInt(qs, "within", 0, v))
+	input.Format = app.readExportFormat(r, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = moduleInfoSortSafeList
+	input.Filters.Filter = app.readString(qs, "filter", "")
+	input.Filters.FilterFields = data.ModuleInfoFilterFields
+
+	data.ValidateSort(v, input.Filters)
+	if data.ValidateFilterExpression(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	e := app.newExporter(w, input.Format, "moduleinfo", []string{"id", "created_at", "updated_at", "module_name", "module_duration", "exam_type", "capacity", "version"})
+	err := app.models.ModuleInfoModel.Export(input.ModuleName, input.ExamType, input.Within, input.Filters, func(m *data.ModuleInfo) error {
+		return e.write(m, []string{
+			strconv.FormatInt(m.ID, 10),
+			csvTime(m.CreatedAt),
+			csvTime(m.UpdatedAt),
+			csvText(m.ModuleName),
+			strconv.Itoa(int(m.ModuleDuration)),
+			m.ExamType,
+			strconv.Itoa(int(m.Capacity)),
+			strconv.Itoa(int(m.Version)),
+		})
+	})
+	app.finishExport(r, w, e, err)
+}
+
+func (app *application) exportDepartmentInfosHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		DepartmentName string
+		DirectorName   string
+		Within         int64
+		Format         string
+		Filters        data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.DepartmentName = app.readString(qs, "departmentname", "")
+	input.DirectorName = app.readString(qs, "departmentdirector", "")
+	input.Within = int64(app.readInt(qs, "within", 0, v))
+	input.Format = app.readExportFormat(r, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = departmentInfoSortSafeList
+	input.Filters.Filter = app.readString(qs, "filter", "")
+	input.Filters.FilterFields = data.DepartmentInfoFilterFields
+
+	data.ValidateSort(v, input.Filters)
+	if data.ValidateFilterExpression(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	e := app.newExporter(w, input.Format, "departmentinfo", []string{"id", "department_name", "staff_quantity", "director_id", "parent_id", "unit_type", "version"})
+	err := app.models.DepartmentInfoModel.Export(input.DepartmentName, input.DirectorName, input.Within, input.Filters, func(d *data.DepartmentInfo) error {
+		return e.write(d, []string{
+			strconv.FormatInt(d.ID, 10),
+			csvText(d.DepartmentName),
+			strconv.Itoa(int(d.StaffQuantity)),
+			strconv.FormatInt(d.DirectorID, 10),
+			strconv.FormatInt(d.ParentID, 10),
+			csvText(d.UnitType),
+			strconv.Itoa(int(d.Version)),
+		})
+	})
+	app.finishExport(r, w, e, err)
+}
+
+// exportUserInfosHandler exports users. The model never selects password hashes, and
+// the columns below are listed explicitly so a field added to UserInfo later is not
+// exported by accident. The email column is left empty for callers without the
+// users:admin permission.
+func (app *application) exportUserInfosHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Fname   string
+		Sname   string
+		Within  int64
+		Format  string
+		Filters data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Fname = app.readString(qs, "fname", "")
+	input.Sname = app.readString(qs, "sname", "")
+	input.Within = int64(app.readInt(qs, "within", 0, v))
+	input.Format = app.readExportFormat(r, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = userInfoSortSafeList
+	input.Filters.Filter = app.readString(qs, "filter", "")
+
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	input.Filters.FilterFields = userInfoFilterFields(showEmails)
+	data.ValidateSort(v, input.Filters)
+	if data.ValidateFilterExpression(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	type exportedUser struct {
+		ID        int64     `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Name      string    `json:"name"`
+		Surname   string    `json:"surname"`
+		Email     string    `json:"email,omitempty"`
+		Role      string    `json:"role"`
+		Activated bool      `json:"activated"`
+	}
+	e := app.newExporter(w, input.Format, "users", []string{"id", "created_at", "updated_at", "name", "surname", "email", "role", "activated"})
+	err = app.models.UserInfoModel.Export(input.Fname, input.Sname, input.Within, input.Filters, func(u *data.UserInfo) error {
+		if !showEmails {
+			u.Email = ""
+		}
+		return e.write(exportedUser{
+			ID:        u.ID,
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+			Name:      u.Name,
+			Surname:   u.Surname,
+			Email:     u.Email,
+			Role:      u.Role,
+			Activated: u.Activated,
+		}, []string{
+			strconv.FormatInt(u.ID, 10),
+			csvTime(u.CreatedAt),
+			csvTime(u.UpdatedAt),
+			csvText(u.Name),
+			csvText(u.Surname),
+			csvText(u.Email),
+			csvText(u.Role),
+			strconv.FormatBool(u.Activated),
+		})
+	})
+	app.finishExport(r, w, e, err)
+}
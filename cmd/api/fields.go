@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/url"
+	"strings"
+)
+
+// readList splits a comma-separated query parameter into its trimmed values. An
+// absent parameter returns nil.
+func (app *application) readList(qs url.Values, key string) []string {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+	values := strings.Split(s, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// readFields reads ?fields=, the JSON fields of record a response should be trimmed
+// to. Without the parameter it returns nil, which keeps every field.
+func (app *application) readFields(qs url.Values, record any, v *validator.Validator) []string {
+	fields := app.readList(qs, "fields")
+	known := jsonFields(record)
+	for _, field := range fields {
+		if _, ok := known[field]; !ok {
+			v.AddError("fields", fmt.Sprintf("unknown field %q", field))
+		}
+	}
+	return fields
+}
+
+// readIncludes reads ?include=, the related resources to embed in each record, each
+// of which must be one of allowed.
+func (app *application) readIncludes(qs url.Values, v *validator.Validator, allowed ...string) []string {
+	includes := app.readList(qs, "include")
+	for _, include := range includes {
+		if !validator.PermittedValue(include, allowed...) {
+			v.AddError("include", fmt.Sprintf("must only contain %s", strings.Join(allowed, ", ")))
+		}
+	}
+	v.Check(validator.Unique(includes), "include", "must not contain duplicate values")
+	return includes
+}
+
+// included reports whether ?include= asked for the related resource name.
+func included(includes []string, name string) bool {
+	return validator.PermittedValue(name, includes...)
+}
+
+// shape returns record as a JSON object holding only fields, or every field when
+// fields is nil, together with the related records in embedded. When there is
+// nothing to trim or embed it returns record itself, so responses to requests
+// without ?fields= or ?include= are unchanged.
+func shape(record any, fields []string, embedded map[string]any) (any, error) {
+	if fields == nil && len(embedded) == 0 {
+		return record, nil
+	}
+	js, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	var object map[string]any
+	err = dec.Decode(&object)
+	if err != nil {
+		return nil, err
+	}
+	if fields != nil {
+		trimmed := make(map[string]any, len(fields)+len(embedded))
+		for _, field := range fields {
+			if value, ok := object[field]; ok {
+				trimmed[field] = value
+			}
+		}
+		object = trimmed
+	}
+	for key, value := range embedded {
+		object[key] = value
+	}
+	return object, nil
+}
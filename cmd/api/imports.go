@@ -0,0 +1,519 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxImportBytes = 16 << 20
+	maxImportRows  = 5000
+	// maxUserImportRows is lower because every row with a password costs a bcrypt
+	// hash within the request.
+	maxUserImportRows = 1000
+	// importTimeout replaces the server-wide timeouts for imports; hashing the
+	// passwords of a large user import alone can take a minute or more.
+	importTimeout = 10 * time.Minute
+	// importActivationTTL is longer than the self-registration token because imported
+	// users did not ask for the account and may not read the email straight away.
+	importActivationTTL = 3 * 24 * time.Hour
+)
+
+// importRow is one line of the import report. Line is the line number in the CSV
+// file, counting the header as line 1.
+type importRow struct {
+	Line   int               `json:"line"`
+	Status string            `json:"status"`
+	ID     int64             `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+const (
+	importStatusValid       = "valid"
+	importStatusCreated     = "created"
+	importStatusInvalid     = "invalid"
+	importStatusFailed      = "failed"
+	importStatusNotImported = "not_imported"
+)
+
+// importOptions are the query string options shared by the import endpoints.
+// skip_invalid imports the good rows and reports the rest; without it a single bad
+// row means nothing is written.
+type importOptions struct {
+	DryRun      bool `json:"dry_run"`
+	SkipInvalid bool `json:"skip_invalid"`
+}
+
+// csvImport reads a CSV body with a header row, one record at a time.
+type csvImport struct {
+	reader  *csv.Reader
+	columns map[string]int
+	record  []string
+	// badRow is set when the current record could not be split into the expected
+	// columns.
+	badRow error
+}
+
+// newCSVImport reads the header row. Column names are matched case-insensitively;
+// every required column must be present and no unknown column is allowed.
+func newCSVImport(body io.Reader, required, optional []string) (*csvImport, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must contain a CSV header row")
+		}
+		return nil, csvError(err)
+	}
+	known := make(map[string]bool)
+	for _, name := range append(required, optional...) {
+		known[name] = true
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing CSV column %q", name)
+		}
+	}
+	return &csvImport{reader: reader, columns: columns}, nil
+}
+
+// next advances to the next record and returns its line number. It returns io.EOF
+// at the end of the body. A record with the wrong number of fields is reported
+// through badRow so the import can carry on; any other CSV error is fatal.
+func (c *csvImport) next() (int, error) {
+	c.record, c.badRow = nil, nil
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount):
+			c.badRow = parseErr.Err
+			return parseErr.StartLine, nil
+		case errors.Is(err, io.EOF):
+			return 0, io.EOF
+		default:
+			return 0, csvError(err)
+		}
+	}
+	c.record = record
+	line, _ := c.reader.FieldPos(0)
+	return line, nil
+}
+
+// field returns the trimmed value of a column in the current record, or "" if the
+// column is absent.
+func (c *csvImport) field(name string) string {
+	i, ok := c.columns[name]
+	if !ok || i >= len(c.record) {
+		return ""
+	}
+	return strings.TrimSpace(c.record[i])
+}
+func csvError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return fmt.Errorf("body mustnt be larger than %d bytes", maxBytesError.Limit)
+	}
+	return fmt.Errorf("body contains malformed CSV: %v", err)
+}
+
+// readImportOptions reads the import options and prepares the request for a large
+// streamed body.
+func (app *application) readImportOptions(w http.ResponseWriter, r *http.Request, v *validator.Validator) importOptions {
+	qs := r.URL.Query()
+	app.extendDeadlines(w, importTimeout)
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	return importOptions{
+		DryRun:      app.readBool(qs, "dry_run", false, v),
+		SkipInvalid: app.readBool(qs, "skip_invalid", false, v),
+	}
+}
+
+// writeImportReport sends the per-row report. An import that was refused because
+// of invalid rows is a 422, so clients that only look at the status still notice.
+func (app *application) writeImportReport(w http.ResponseWriter, r *http.Request, options importOptions, rows []*importRow) {
+	summary := map[string]any{
+		"dry_run":      options.DryRun,
+		"skip_invalid": options.SkipInvalid,
+		"rows":         len(rows),
+	}
+	counts := make(map[string]int)
+	for _, row := range rows {
+		counts[row.Status]++
+	}
+	for status, count := range counts {
+		summary[status] = count
+	}
+	refused := counts[importStatusInvalid] + counts[importStatusFailed] + counts[importStatusNotImported]
+	status := http.StatusOK
+	switch {
+	case !options.DryRun && !options.SkipInvalid && refused > 0:
+		status = http.StatusUnprocessableEntity
+	case !options.DryRun && counts[importStatusCreated] > 0:
+		status = http.StatusCreated
+	}
+	err := app.writeJSON(w, status, envelope{"import": summary, "report": rows}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// finishImportReport applies the outcome of the bulk insert to the rows that were
+// sent to it.
+func finishImportReport(rows []*importRow, ids []int64, rowErrs []error, aborted bool, describe func(error) map[string]string) {
+	for i, row := range rows {
+		switch {
+		case rowErrs != nil && rowErrs[i] != nil:
+			row.Status = importStatusFailed
+			row.Errors = describe(rowErrs[i])
+		case aborted:
+			row.Status = importStatusNotImported
+		default:
+			row.Status = importStatusCreated
+			row.ID = ids[i]
+		}
+	}
+}
+
+// markNotImported flags the valid rows of an import that is refused because other
+// rows are invalid.
+func markNotImported(rows []*importRow) {
+	for _, row := range rows {
+		if row.Status == importStatusValid {
+			row.Status = importStatusNotImported
+		}
+	}
+}
+
+func (app *application) importModuleInfosHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	options := app.readImportOptions(w, r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	examTypes, err := app.models.ExamTypes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	knownExamTypes := make(map[string]bool, len(examTypes))
+	for _, examType := range examTypes {
+		knownExamTypes[examType.Code] = true
+	}
+
+	in, err := newCSVImport(r.Body, []string{"module_name", "module_duration", "exam_type"}, []string{"capacity"})
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	report := []*importRow{}
+	pending := []*importRow{}
+	moduleInfos := []*data.ModuleInfo{}
+	userID := app.contextGetUser(r).ID
+	for {
+		line, err := in.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if len(report) == maxImportRows {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not contain more than %d rows", maxImportRows))
+			return
+		}
+		row := &importRow{Line: line, Status: importStatusValid}
+		report = append(report, row)
+		rv := validator.New()
+		if in.badRow != nil {
+			rv.AddError("row", in.badRow.Error())
+		} else {
+			moduleInfo := &data.ModuleInfo{
+				ModuleName: in.field("module_name"),
+				ExamType:   in.field("exam_type"),
+				Capacity:   30,
+				UpdatedBy:  userID,
+			}
+			moduleInfo.ModuleDuration = readCSVInt32(in.field("module_duration"), "moduleDuration", moduleInfo.ModuleDuration, rv)
+			moduleInfo.Capacity = readCSVInt32(in.field("capacity"), "capacity", moduleInfo.Capacity, rv)
+			data.ValidateModuleInfo(rv, moduleInfo)
+			if rv.Valid() {
+				rv.Check(knownExamTypes[moduleInfo.ExamType], "examType", "must reference a known exam type code")
+			}
+			if rv.Valid() {
+				pending = append(pending, row)
+				moduleInfos = append(moduleInfos, moduleInfo)
+			}
+		}
+		if !rv.Valid() {
+			row.Status = importStatusInvalid
+			row.Errors = rv.Errors
+		}
+	}
+	invalid := len(report) - len(pending)
+	if options.DryRun || len(pending) == 0 {
+		app.writeImportReport(w, r, options, report)
+		return
+	}
+	if invalid > 0 && !options.SkipInvalid {
+		markNotImported(report)
+		app.writeImportReport(w, r, options, report)
+		return
+	}
+	rowErrs, err := app.models.ModuleInfoModel.InsertMany(moduleInfos, options.SkipInvalid)
+	if err != nil && !errors.Is(err, data.ErrImportAborted) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	ids := make([]int64, len(moduleInfos))
+	for i, moduleInfo := range moduleInfos {
+		ids[i] = moduleInfo.ID
+	}
+	finishImportReport(pending, ids, rowErrs, err != nil, func(err error) map[string]string {
+		return map[string]string{"row": "the database rejected this row"}
+	})
+	app.writeImportReport(w, r, options, report)
+}
+
+// importUserInfosHandler creates accounts from a CSV file. A row without a password
+// gets an account no password matches, and the user chooses one when activating it
+// with the token they are sent. Passwords are never mailed. Because it creates
+// accounts for other people and mails them, the route takes users:admin.
+func (app *application) importUserInfosHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	options := app.readImportOptions(w, r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	in, err := newCSVImport(r.Body, []string{"fname", "sname", "email"}, []string{"role", "password"})
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	type pendingUser struct {
+		row      *importRow
+		user     *data.UserInfo
+		password string
+	}
+	report := []*importRow{}
+	pending := []*pendingUser{}
+	seen := make(map[string]int)
+	for {
+		line, err := in.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if len(report) == maxUserImportRows {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not contain more than %d rows", maxUserImportRows))
+			return
+		}
+		row := &importRow{Line: line, Status: importStatusValid}
+		report = append(report, row)
+		rv := validator.New()
+		if in.badRow != nil {
+			rv.AddError("row", in.badRow.Error())
+		} else {
+			p := &pendingUser{
+				row: row,
+				user: &data.UserInfo{
+					Name:    in.field("fname"),
+					Surname: in.field("sname"),
+					Email:   in.field("email"),
+					Role:    in.field("role"),
+				},
+				password: in.field("password"),
+			}
+			data.ValidateUserInput(rv, p.user, p.password)
+			email := strings.ToLower(p.user.Email)
+			if first, ok := seen[email]; ok {
+				rv.AddError("email", fmt.Sprintf("duplicates the email address on line %d", first))
+			} else {
+				seen[email] = line
+			}
+			if rv.Valid() {
+				pending = append(pending, p)
+			}
+		}
+		if !rv.Valid() {
+			row.Status = importStatusInvalid
+			row.Errors = rv.Errors
+		}
+	}
+
+	emails := make([]string, len(pending))
+	for i, p := range pending {
+		emails[i] = p.user.Email
+	}
+	existing, err := app.models.UserInfoModel.ExistingEmails(emails)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	valid := pending[:0]
+	for _, p := range pending {
+		if existing[strings.ToLower(p.user.Email)] {
+			p.row.Status = importStatusInvalid
+			p.row.Errors = map[string]string{"email": "a user with this email address already exists"}
+			continue
+		}
+		valid = append(valid, p)
+	}
+	pending = valid
+
+	invalid := len(report) - len(pending)
+	if options.DryRun || len(pending) == 0 {
+		app.writeImportReport(w, r, options, report)
+		return
+	}
+	if invalid > 0 && !options.SkipInvalid {
+		markNotImported(report)
+		app.writeImportReport(w, r, options, report)
+		return
+	}
+
+	users := make([]*data.UserInfo, len(pending))
+	passwords := make([]string, len(pending))
+	for i, p := range pending {
+		users[i] = p.user
+		passwords[i] = p.password
+	}
+	err = hashPasswords(users, passwords)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	rowErrs, err := app.models.UserInfoModel.InsertMany(users, []string{"movies:read"}, options.SkipInvalid)
+	if err != nil && !errors.Is(err, data.ErrImportAborted) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	aborted := err != nil
+	rows := make([]*importRow, len(pending))
+	ids := make([]int64, len(pending))
+	for i, p := range pending {
+		rows[i] = p.row
+		ids[i] = p.user.ID
+	}
+	finishImportReport(rows, ids, rowErrs, aborted, func(err error) map[string]string {
+		if errors.Is(err, data.ErrDuplicateEmail) {
+			return map[string]string{"email": "a user with this email address already exists"}
+		}
+		return map[string]string{"row": "the database rejected this row"}
+	})
+
+	if !aborted {
+		for i, p := range pending {
+			if rowErrs[i] != nil {
+				continue
+			}
+			err = app.sendImportedUserEmail(p.user)
+			if err != nil {
+				app.logError(r, err)
+			}
+		}
+	}
+	app.writeImportReport(w, r, options, report)
+}
+
+// sendImportedUserEmail creates an activation token for an imported user and mails
+// it in the background, asking for a password as well if the import set none.
+func (app *application) sendImportedUserEmail(user *data.UserInfo) error {
+	token, err := app.models.Tokens.New(user.ID, importActivationTTL, data.ScopeActivation)
+	if err != nil {
+		return err
+	}
+	app.background(func() {
+		data := map[string]any{
+			"activationToken": token.Plaintext,
+			"userID":          user.ID,
+			"name":            user.Name,
+			"choosePassword":  !user.Password.Usable(),
+		}
+		err := app.mailer.Send(user.Email, "user_imported.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+	return nil
+}
+
+// hashPasswords sets the password of users[i] to passwords[i], or makes it unusable
+// where passwords[i] is empty. bcrypt dominates the cost of a user import, so the
+// hashes are computed on every CPU.
+func hashPasswords(users []*data.UserInfo, passwords []string) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	jobs := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var err error
+				if passwords[i] == "" {
+					err = users[i].Password.SetUnusable()
+				} else {
+					err = users[i].Password.Set(passwords[i])
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range users {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// readCSVInt32 parses an integer column. An empty value keeps the default.
+func readCSVInt32(s string, key string, defaultValue int32, v *validator.Validator) int32 {
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return int32(i)
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"flag"
 	_ "github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/blobstore"
 	"golangHW.darkhanomirbay/internal/data"
 	"golangHW.darkhanomirbay/internal/jsonlog"
 	"golangHW.darkhanomirbay/internal/mailer"
@@ -36,12 +37,25 @@ type config struct {
 		password string
 		sender   string
 	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	attachments struct {
+		dir      string
+		maxBytes int64
+	}
+	stats struct {
+		refreshInterval time.Duration
+	}
 }
 type application struct {
 	config config
 	logger *jsonlog.Logger
 	models data.Models
 	mailer mailer.Mailer
+	blobs  blobstore.Store
+	stats  *statsCache
 	wg     sync.WaitGroup
 }
 
@@ -65,6 +79,13 @@ func main() {
 	flag.StringVar(&cfg.smtp.username, "smtp-username", "29e20c93d498ff", "SMTP username")
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "4672af6936d913", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "<220373@astanait.edu.kz> ", "SMTP sender")
+
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted records stay restorable (0 keeps them forever)")
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "How often expired records are purged from the trash")
+
+	flag.StringVar(&cfg.attachments.dir, "attachments-dir", "./uploads", "Directory for uploaded module attachments")
+	flag.Int64Var(&cfg.attachments.maxBytes, "attachments-max-bytes", 20<<20, "Maximum size of a single attachment in bytes")
+	flag.DurationVar(&cfg.stats.refreshInterval, "stats-refresh-interval", 10*time.Minute, "How often cached statistics are recomputed")
 	flag.Parse()
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
@@ -74,13 +95,21 @@ func main() {
 	}
 	defer db.Close()
 	logger.PrintInfo("database connection pool established", nil)
+	blobs, err := blobstore.NewLocal(cfg.attachments.dir)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
 		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		blobs:  blobs,
+		stats:  newStatsCache(),
 	}
 
+	go app.purgeTrash()
+	go app.refreshStats()
 	app.gorout()
 
 	err = app.serve()
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+const maxBatchOperations = 100
+
+// batchOperation is one entry of a batch request. Updates carry only the fields
+// they change plus the version they expect to replace; deletes carry the id and,
+// optionally, a version.
+type batchOperation struct {
+	Action         string  `json:"action"`
+	ID             int64   `json:"id"`
+	Version        *int32  `json:"version"`
+	ModuleName     *string `json:"module_name"`
+	ModuleDuration *int32  `json:"module_duration"`
+	ExamType       *string `json:"exam_type"`
+	Capacity       *int32  `json:"capacity"`
+}
+
+// batchResult reports what happened to one operation. Index is its position in the
+// request, counting from zero.
+type batchResult struct {
+	Index      int               `json:"index"`
+	Action     string            `json:"action"`
+	Status     string            `json:"status"`
+	ID         int64             `json:"id,omitempty"`
+	ModuleInfo *data.ModuleInfo  `json:"module_info,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+const (
+	batchStatusApplied    = "applied"
+	batchStatusInvalid    = "invalid"
+	batchStatusNotFound   = "not_found"
+	batchStatusConflict   = "conflict"
+	batchStatusNotApplied = "not_applied"
+)
+
+// postModuleInfoHandler serves POST /v1/moduleinfo/:id. httprouter cannot hold a
+// static /v1/moduleinfo/batch next to the :id routes, so the batch endpoint is
+// reached through :id and every other id is not found.
+func (app *application) postModuleInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") != "batch" {
+		app.notFoundResponse(w, r)
+		return
+	}
+	app.moduleBatchHandler(w, r)
+}
+
+// moduleBatchHandler applies a list of module creates, updates and deletes
+// atomically. Every operation is checked up front against the current state of
+// the modules (and against the earlier operations of the same batch) so that all
+// problems are reported at once; the batch is then run in one transaction, which
+// catches anything that changed in the meantime. Either way, a failing batch
+// changes nothing.
+func (app *application) moduleBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Operations []batchOperation `json:"operations"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(len(input.Operations) > 0, "operations", "must contain at least one operation")
+	v.Check(len(input.Operations) <= maxBatchOperations, "operations", fmt.Sprintf("must not contain more than %d operations", maxBatchOperations))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID := app.contextGetUser(r).ID
+	ops := make([]data.ModuleBatchOp, len(input.Operations))
+	results := make([]*batchResult, len(input.Operations))
+	// staged holds each module touched so far as the batch will have left it; nil
+	// means the batch deletes it.
+	staged := make(map[int64]*data.ModuleInfo)
+	knownExamTypes := make(map[string]bool)
+	failed := false
+
+	for i, op := range input.Operations {
+		result := &batchResult{Index: i, Action: op.Action, ID: op.ID}
+		results[i] = result
+		v := validator.New()
+
+		v.Check(validator.PermittedValue(op.Action, data.BatchActions...), "action", "must be create, update or delete")
+		switch op.Action {
+		case data.BatchCreate:
+			v.Check(op.ID == 0, "id", "must not be provided for create")
+			v.Check(op.Version == nil, "version", "must not be provided for create")
+		case data.BatchUpdate:
+			v.Check(op.ID > 0, "id", "must be provided")
+			v.Check(op.Version != nil, "version", "must be provided")
+		case data.BatchDelete:
+			v.Check(op.ID > 0, "id", "must be provided")
+		}
+		if !v.Valid() {
+			result.Status, result.Errors, failed = batchStatusInvalid, v.Errors, true
+			continue
+		}
+
+		moduleInfo := &data.ModuleInfo{Capacity: 30}
+		if op.Action != data.BatchCreate {
+			current, ok := staged[op.ID]
+			if !ok {
+				current, err = app.models.ModuleInfoModel.Get(op.ID)
+				if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+					app.serverErrorResponse(w, r, err)
+					return
+				}
+			}
+			if current == nil {
+				result.Status, failed = batchStatusNotFound, true
+				continue
+			}
+			if op.Version != nil && *op.Version != current.Version {
+				result.Status, failed = batchStatusConflict, true
+				continue
+			}
+			copied := *current
+			moduleInfo = &copied
+		}
+
+		if op.Action == data.BatchDelete {
+			// Without a version the client accepts whatever state the module is
+			// in when the batch runs.
+			var version int32
+			if op.Version != nil {
+				version = *op.Version
+			}
+			staged[op.ID] = nil
+			ops[i] = data.ModuleBatchOp{Action: op.Action, ModuleInfo: &data.ModuleInfo{ID: op.ID, Version: version}}
+			continue
+		}
+
+		if op.ModuleName != nil {
+			moduleInfo.ModuleName = *op.ModuleName
+		}
+		if op.ModuleDuration != nil {
+			moduleInfo.ModuleDuration = *op.ModuleDuration
+		}
+		if op.ExamType != nil {
+			moduleInfo.ExamType = *op.ExamType
+		}
+		if op.Capacity != nil {
+			moduleInfo.Capacity = *op.Capacity
+		}
+		moduleInfo.UpdatedBy = userID
+		data.ValidateModuleInfo(v, moduleInfo)
+		if v.Valid() && (op.Action == data.BatchCreate || op.ExamType != nil) {
+			known, checked := knownExamTypes[moduleInfo.ExamType]
+			if !checked {
+				err = app.checkExamType(v, "examType", moduleInfo.ExamType)
+				if err != nil {
+					app.serverErrorResponse(w, r, err)
+					return
+				}
+				known = v.Valid()
+				knownExamTypes[moduleInfo.ExamType] = known
+			}
+			if !known {
+				v.AddError("examType", "must reference a known exam type code")
+			}
+		}
+		if !v.Valid() {
+			result.Status, result.Errors, failed = batchStatusInvalid, v.Errors, true
+			continue
+		}
+
+		ops[i] = data.ModuleBatchOp{Action: op.Action, ModuleInfo: moduleInfo}
+		if op.Action == data.BatchUpdate {
+			next := *moduleInfo
+			next.Version++
+			staged[op.ID] = &next
+		}
+	}
+	if failed {
+		app.writeBatchFailure(w, r, results)
+		return
+	}
+
+	index, err := app.models.ModuleInfoModel.ApplyBatch(ops)
+	if err != nil {
+		switch {
+		case index >= 0 && errors.Is(err, data.ErrEditConflict):
+			results[index].Status = batchStatusConflict
+		case index >= 0 && errors.Is(err, data.ErrRecordNotFound):
+			results[index].Status = batchStatusNotFound
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.writeBatchFailure(w, r, results)
+		return
+	}
+
+	for i, op := range ops {
+		results[i].Status = batchStatusApplied
+		results[i].ID = op.ModuleInfo.ID
+		if op.Action != data.BatchDelete {
+			results[i].ModuleInfo = op.ModuleInfo
+		}
+		// A raised capacity may free places for waitlisted students.
+		if op.Action == data.BatchUpdate && input.Operations[i].Capacity != nil {
+			promoted, err := app.models.Enrollments.Promote(op.ModuleInfo.ID)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+			app.notifyPromoted(op.ModuleInfo, promoted)
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// writeBatchFailure reports a batch that was not applied. Operations that were fine
+// on their own are marked not_applied. The status code follows the most serious
+// problem: invalid input, then a missing module, then a version conflict.
+func (app *application) writeBatchFailure(w http.ResponseWriter, r *http.Request, results []*batchResult) {
+	counts := make(map[string]int)
+	for _, result := range results {
+		if result.Status == "" {
+			result.Status = batchStatusNotApplied
+		}
+		counts[result.Status]++
+	}
+	status := http.StatusConflict
+	switch {
+	case counts[batchStatusInvalid] > 0:
+		status = http.StatusUnprocessableEntity
+	case counts[batchStatusNotFound] > 0:
+		status = http.StatusNotFound
+	}
+	err := app.writeJSON(w, status, envelope{"error": "the batch was not applied", "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+func (app *application) getModuleHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Filters data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-version")
+	input.Filters.SortSafeList = []string{"version", "-version", "changed_at", "-changed_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	_, err = app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	revisions, metadata, err := app.models.ModuleHistory.GetAll(id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"history": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getModuleHistoryDiffHandler compares two revisions of a module. "to" defaults to
+// the current version and "from" to the version just before it.
+func (app *application) getModuleHistoryDiffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	moduleInfo, err := app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	to := int32(app.readInt(qs, "to", int(moduleInfo.Version), v))
+	from := int32(app.readInt(qs, "from", int(to-1), v))
+	v.Check(from > 0, "from", "must be greater than zero")
+	v.Check(to > 0, "to", "must be greater than zero")
+	v.Check(to <= moduleInfo.Version, "to", "must not be after the current version")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	fromRevision, err := app.models.ModuleHistory.Get(id, from)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("from", "no such version in the module history")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	toRevision, err := app.models.ModuleHistory.Get(id, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("to", "no such version in the module history")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	diff := envelope{
+		"from":    fromRevision,
+		"to":      toRevision,
+		"changes": data.DiffRevisions(fromRevision, toRevision),
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"diff": diff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertModuleInfoHandler restores the fields of an earlier revision. The revert is an
+// ordinary edit: it goes through Update with the version just read, so it fails with
+// an edit conflict if the module changes underneath it, and it is itself recorded as a
+// new revision rather than rewriting history.
+func (app *application) revertModuleInfoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	version, err := app.readNamedIDParam(r, "version")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	moduleInfo, err := app.models.ModuleInfoModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	v := validator.New()
+	if v.Check(version < int64(moduleInfo.Version), "version", "must be older than the current version"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	revision, err := app.models.ModuleHistory.Get(id, int32(version))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	capacityChanged := moduleInfo.Capacity != revision.Capacity
+	moduleInfo.ModuleName = revision.ModuleName
+	moduleInfo.ModuleDuration = revision.ModuleDuration
+	moduleInfo.ExamType = revision.ExamType
+	moduleInfo.Capacity = revision.Capacity
+	moduleInfo.UpdatedBy = app.contextGetUser(r).ID
+
+	if data.ValidateModuleInfo(v, moduleInfo); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.checkExamType(v, "examType", moduleInfo.ExamType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.ModuleInfoModel.Update(moduleInfo)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if capacityChanged {
+		promoted, err := app.models.Enrollments.Promote(moduleInfo.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.notifyPromoted(moduleInfo, promoted)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated module info": moduleInfo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
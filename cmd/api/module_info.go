@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"github.com/julienschmidt/httprouter"
 	"golangHW.darkhanomirbay/internal/data"
 	"golangHW.darkhanomirbay/internal/validator"
 	"net/http"
@@ -13,6 +14,7 @@ func (app *application) createModuleInfoHandler(w http.ResponseWriter, r *http.R
 		ModuleName     string `json:"module_name"`
 		ModuleDuration int32  `json:"module_duration"`
 		ExamType       string `json:"exam_type"`
+		Capacity       *int32 `json:"capacity"`
 	}
 	//body, err := io.ReadAll(r.Body)
 	//if err != nil {
@@ -24,20 +26,36 @@ func (app *application) createModuleInfoHandler(w http.ResponseWriter, r *http.R
 	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 	v := validator.New()
 	moduleInfo := &data.ModuleInfo{
 		ModuleName:     input.ModuleName,
 		ModuleDuration: input.ModuleDuration,
 		ExamType:       input.ExamType,
+		Capacity:       30,
+		UpdatedBy:      app.contextGetUser(r).ID,
+	}
+	if input.Capacity != nil {
+		moduleInfo.Capacity = *input.Capacity
 	}
 	if data.ValidateModuleInfo(v, moduleInfo); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	err = app.checkExamType(v, "examType", moduleInfo.ExamType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 	err = app.models.ModuleInfoModel.Insert(moduleInfo)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/moduleinfo/%d", moduleInfo.ID))
@@ -47,10 +65,26 @@ func (app *application) createModuleInfoHandler(w http.ResponseWriter, r *http.R
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// getModuleInfoHandler also serves GET /v1/moduleinfo/suggest, which httprouter
+// cannot hold as a static route next to :id.
 func (app *application) getModuleInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "suggest" {
+		app.suggestModuleInfosHandler(w, r)
+		return
+	}
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	fields := app.readFields(qs, &data.ModuleInfo{}, v)
+	includes := app.readIncludes(qs, v, moduleInfoIncludes...)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 	moduleInfo, err := app.models.ModuleInfoModel.Get(id)
 	if err != nil {
@@ -63,15 +97,67 @@ func (app *application) getModuleInfoHandler(w http.ResponseWriter, r *http.Requ
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"module info": moduleInfo}, nil)
+	// Embedded departments can change without the module's version moving, so the
+	// version only identifies the representation when nothing is embedded.
+	if len(includes) == 0 && app.notModified(w, r, moduleInfo.Version) {
+		return
+	}
+	shaped, err := app.shapeModuleInfos([]*data.ModuleInfo{moduleInfo}, fields, includes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"module info": shaped[0]}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// moduleInfoSortSafeList is shared by the list and export handlers.
+var moduleInfoSortSafeList = []string{"module_name", "-module_name", "module_duration", "-module_duration", "exam_type", "-exam_type", "capacity", "-capacity", "id", "-id"}
+
+// moduleInfoIncludes are the related resources ?include= can embed in a module.
+var moduleInfoIncludes = []string{"departments"}
+
+// shapeModuleInfos applies ?fields= and ?include= to moduleInfos. Related records
+// are loaded for all of them with one query per include.
+func (app *application) shapeModuleInfos(moduleInfos []*data.ModuleInfo, fields, includes []string) ([]any, error) {
+	ids := make([]int64, len(moduleInfos))
+	for i, moduleInfo := range moduleInfos {
+		ids[i] = moduleInfo.ID
+	}
+	var departmentInfos map[int64][]*data.DepartmentInfo
+	if included(includes, "departments") {
+		var err error
+		departmentInfos, err = app.models.DepartmentModules.GetDepartmentsForModules(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	shaped := make([]any, len(moduleInfos))
+	for i, moduleInfo := range moduleInfos {
+		embedded := make(map[string]any)
+		if departmentInfos != nil {
+			departments := departmentInfos[moduleInfo.ID]
+			if departments == nil {
+				departments = []*data.DepartmentInfo{}
+			}
+			embedded["departments"] = departments
+		}
+		var err error
+		shaped[i], err = shape(moduleInfo, fields, embedded)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return shaped, nil
+}
+
 func (app *application) getAllModuleInfos(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		ModuleName string
 		ExamType   string
+		Within     int64
 		Filters    data.Filters
 	}
 	v := validator.New()
@@ -79,21 +165,39 @@ func (app *application) getAllModuleInfos(w http.ResponseWriter, r *http.Request
 
 	input.ModuleName = app.readString(qs, "modulename", "")
 	input.ExamType = app.readString(qs, "examtype", "")
+	input.Within = int64(app.readInt(qs, "within", 0, v))
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"modulename", "-modulename", "moduleduration", "-moduleduration", "id", "-id", "moduleduration", "-moduleduration"}
+	input.Filters.SortSafeList = moduleInfoSortSafeList
+	input.Filters.Filter = app.readString(qs, "filter", "")
+	input.Filters.FilterFields = data.ModuleInfoFilterFields
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.SkipCount = !app.readBool(qs, "count", input.Filters.Cursor == "", v)
+	fields := app.readFields(qs, &data.ModuleInfo{}, v)
+	includes := app.readIncludes(qs, v, moduleInfoIncludes...)
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	moduleinfo, metadata, err := app.models.ModuleInfoModel.GetAll(input.ModuleName, input.ExamType, input.Filters)
+	moduleinfo, metadata, err := app.models.ModuleInfoModel.GetAll(input.ModuleName, input.ExamType, input.Within, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "is invalid")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	shaped, err := app.shapeModuleInfos(moduleinfo, fields, includes)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"module infos": moduleinfo, "metadata": metadata}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"module infos": shaped, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -112,15 +216,21 @@ func (app *application) editModuleInfoHandler(w http.ResponseWriter, r *http.Req
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
+		return
+	}
+	if !app.checkIfMatch(w, r, moduleInfo.Version) {
+		return
 	}
 	var input struct {
 		ModuleName     *string `json:"module_name"`
 		ModuleDuration *int32  `json:"module_duration"`
 		ExamType       *string `json:"exam_type"`
+		Capacity       *int32  `json:"capacity"`
 	}
-	err = app.readJSON(w, r, &input)
+	err = app.readPatch(w, r, moduleInfo, &input)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.patchErrorResponse(w, r, err)
+		return
 	}
 	if input.ModuleName != nil {
 		moduleInfo.ModuleName = *input.ModuleName
@@ -131,21 +241,48 @@ func (app *application) editModuleInfoHandler(w http.ResponseWriter, r *http.Req
 	if input.ExamType != nil {
 		moduleInfo.ExamType = *input.ExamType
 	}
+	if input.Capacity != nil {
+		moduleInfo.Capacity = *input.Capacity
+	}
+	moduleInfo.UpdatedBy = app.contextGetUser(r).ID
 	v := validator.New()
 	if data.ValidateModuleInfo(v, moduleInfo); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if input.ExamType != nil {
+		err = app.checkExamType(v, "examType", moduleInfo.ExamType)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
 	}
 	err = app.models.ModuleInfoModel.Update(moduleInfo)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
-			app.editConflicResponse(w, r)
+			app.versionConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 
 		}
+		return
+	}
+	if input.Capacity != nil {
+		promoted, err := app.models.Enrollments.Promote(moduleInfo.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.notifyPromoted(moduleInfo, promoted)
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"updated module info": moduleInfo}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(moduleInfo.Version))
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated module info": moduleInfo}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -156,15 +293,36 @@ func (app *application) deleteModuleInfoHandler(w http.ResponseWriter, r *http.R
 		app.notFoundResponse(w, r)
 		return
 	}
-	err = app.models.ModuleInfoModel.Delete(id)
+	// With If-Match the delete only goes ahead if the module is still at the
+	// version the client last saw.
+	var version int32
+	if r.Header.Get("If-Match") != "" {
+		moduleInfo, err := app.models.ModuleInfoModel.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !app.checkIfMatch(w, r, moduleInfo.Version) {
+			return
+		}
+		version = moduleInfo.Version
+	}
+	err = app.models.ModuleInfoModel.Delete(id, version)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.versionConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
-
+		return
 	}
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "module info successfully deleted"}, nil)
 	if err != nil {
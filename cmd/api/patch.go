@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/jsonpatch"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	mediaTypeJSON       = "application/json"
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// readOnlyFieldError is returned by readPatch when a patch changes a field the
+// endpoint does not let clients edit.
+type readOnlyFieldError struct {
+	field string
+}
+
+func (e readOnlyFieldError) Error() string {
+	return fmt.Sprintf("%s cannot be changed", e.field)
+}
+
+// readPatch applies the request body to current, the resource as clients see it,
+// and decodes the fields whose value changed into dst, the handler's struct of
+// pointer fields. Fields the patch leaves alone stay nil, so handlers copy over
+// only what changed; a field the patch removes or sets to null is decoded as its
+// zero value.
+//
+// The body may be an RFC 7396 merge patch, an RFC 6902 JSON Patch or, as before
+// either existed, plain JSON. Plain JSON is treated as a merge patch in which
+// null leaves a field unchanged rather than clearing it.
+func (app *application) readPatch(w http.ResponseWriter, r *http.Request, current any, dst any) error {
+	mediaType := mediaTypeJSON
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return errUnsupportedMediaType
+		}
+	}
+	if mediaType != mediaTypeJSON && mediaType != mediaTypeMergePatch && mediaType != mediaTypeJSONPatch {
+		return errUnsupportedMediaType
+	}
+
+	var patch any
+	err := app.readJSON(w, r, &patch)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		return err
+	}
+
+	var patched any
+	switch mediaType {
+	case mediaTypeJSONPatch:
+		patched, err = jsonpatch.Apply(doc, patch)
+		if err != nil {
+			return err
+		}
+	default:
+		object, ok := patch.(map[string]any)
+		if !ok {
+			return errors.New("body must be a JSON object")
+		}
+		if mediaType == mediaTypeJSON {
+			for key, value := range object {
+				if value == nil {
+					delete(object, key)
+				}
+			}
+		}
+		patched = jsonpatch.Merge(doc, object)
+	}
+	patchedObject, ok := patched.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: the patched document must be an object", jsonpatch.ErrPath)
+	}
+
+	fields := jsonFields(dst)
+	changes := make(map[string]any)
+	for key, value := range patchedObject {
+		if old, ok := doc[key]; !ok || !reflect.DeepEqual(old, value) {
+			changes[key] = value
+		}
+	}
+	for key := range doc {
+		if _, ok := patchedObject[key]; !ok {
+			changes[key] = nil
+		}
+	}
+	for key, value := range changes {
+		fieldType, ok := fields[key]
+		if !ok {
+			return readOnlyFieldError{field: key}
+		}
+		if value == nil {
+			changes[key] = zeroValue(fieldType)
+		}
+	}
+
+	js, err = json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(bytes.NewReader(js)).Decode(dst)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		if errors.As(err, &unmarshalTypeError) {
+			return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		}
+		return err
+	}
+	return nil
+}
+
+// patchErrorResponse sends the response for an error returned by readPatch.
+func (app *application) patchErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var readOnly readOnlyFieldError
+	switch {
+	case errors.Is(err, errUnsupportedMediaType):
+		app.unsupportedMediaTypeResponse(w, r)
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		app.errorResponse(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, jsonpatch.ErrPath):
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.As(err, &readOnly):
+		app.failedValidationResponse(w, r, map[string]string{readOnly.field: "cannot be changed"})
+	default:
+		app.badRequestResponse(w, r, err)
+	}
+}
+
+// jsonFields maps the JSON names of the fields of the struct dst points to onto
+// their types.
+func jsonFields(dst any) map[string]reflect.Type {
+	t := reflect.TypeOf(dst).Elem()
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// zeroValue is what a cleared field of type t is set to. Slices become empty
+// rather than nil so that clearing one is not mistaken for leaving it alone.
+func zeroValue(t reflect.Type) any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		return reflect.MakeSlice(t, 0, 0).Interface()
+	}
+	return reflect.Zero(t).Interface()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+// validateProgram runs the static program checks and, if those pass, checks the
+// semester totals against the current module durations. The returned error is only
+// set when the durations could not be loaded.
+func (app *application) validateProgram(v *validator.Validator, program *data.Program) error {
+	if data.ValidateProgram(v, program); !v.Valid() {
+		return nil
+	}
+	moduleIDs := make([]int64, 0, len(program.Modules))
+	for _, placement := range program.Modules {
+		moduleIDs = append(moduleIDs, placement.ModuleID)
+	}
+	durations, err := app.models.Programs.ModuleDurations(moduleIDs)
+	if err != nil {
+		return err
+	}
+	data.ValidateProgramDurations(v, program, durations)
+	return nil
+}
+func (app *application) createProgramHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ProgramName         string               `json:"program_name"`
+		SemesterCount       int32                `json:"semester_count"`
+		MaxSemesterDuration int32                `json:"max_semester_duration"`
+		Modules             []data.ProgramModule `json:"modules"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	program := &data.Program{
+		ProgramName:         input.ProgramName,
+		SemesterCount:       input.SemesterCount,
+		MaxSemesterDuration: input.MaxSemesterDuration,
+		Modules:             input.Modules,
+	}
+	if program.Modules == nil {
+		program.Modules = []data.ProgramModule{}
+	}
+	v := validator.New()
+	err = app.validateProgram(v, program)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Programs.Insert(program)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("modules", "must only reference existing modules")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/programs/%d", program.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"program": program}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getProgramHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	program, err := app.models.Programs.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"program": program}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getProgramViewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	view, err := app.models.Programs.GetView(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"program view": view}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getAllProgramsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ProgramName string
+		Filters     data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.ProgramName = app.readString(qs, "program_name", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"program_name", "-program_name", "semester_count", "-semester_count", "id", "-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	programs, metadata, err := app.models.Programs.GetAll(input.ProgramName, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"programs": programs, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) editProgramHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	program, err := app.models.Programs.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		ProgramName         *string              `json:"program_name"`
+		SemesterCount       *int32               `json:"semester_count"`
+		MaxSemesterDuration *int32               `json:"max_semester_duration"`
+		Modules             []data.ProgramModule `json:"modules"`
+	}
+	err = app.readPatch(w, r, program, &input)
+	if err != nil {
+		app.patchErrorResponse(w, r, err)
+		return
+	}
+	if input.ProgramName != nil {
+		program.ProgramName = *input.ProgramName
+	}
+	if input.SemesterCount != nil {
+		program.SemesterCount = *input.SemesterCount
+	}
+	if input.MaxSemesterDuration != nil {
+		program.MaxSemesterDuration = *input.MaxSemesterDuration
+	}
+	if input.Modules != nil {
+		program.Modules = input.Modules
+	}
+	v := validator.New()
+	err = app.validateProgram(v, program)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Programs.Update(program)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflicResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("modules", "must only reference existing modules")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated program": program}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) deleteProgramHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Programs.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "program successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
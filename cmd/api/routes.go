@@ -13,9 +13,87 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo", app.requirePermission("movies:read", app.getAllModuleInfos))
 	router.HandlerFunc(http.MethodPatch, "/v1/moduleinfo/:id", app.requirePermission("movies:read", app.editModuleInfoHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/moduleinfo/:id", app.requirePermission("movies:read", app.deleteModuleInfoHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/moduleinfo/:id", app.requirePermission("movies:read", app.postModuleInfoHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/history", app.requirePermission("movies:read", app.getModuleHistoryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/history/diff", app.requirePermission("movies:read", app.getModuleHistoryDiffHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/moduleinfo/:id/revert/:version", app.requirePermission("movies:read", app.revertModuleInfoHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/attachments", app.requirePermission("movies:read", app.getModuleAttachmentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/moduleinfo/:id/attachments", app.requirePermission("movies:read", app.uploadModuleAttachmentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/attachments/:attachment_id", app.requirePermission("movies:read", app.getModuleAttachmentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/attachments/:attachment_id/content", app.requirePermission("movies:read", app.downloadModuleAttachmentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/moduleinfo/:id/attachments/:attachment_id", app.requirePermission("movies:read", app.deleteModuleAttachmentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/departments", app.requirePermission("movies:read", app.getModuleDepartmentsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/prerequisites", app.requirePermission("movies:read", app.getPrerequisitesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/moduleinfo/:id/prerequisites", app.requirePermission("movies:read", app.addPrerequisiteHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/moduleinfo/:id/prerequisites/:prerequisite_id", app.requirePermission("movies:read", app.removePrerequisiteHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/enrollments", app.requirePermission("movies:read", app.getModuleEnrollmentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/moduleinfo/:id/enrollments", app.requirePermission("movies:read", app.enrollHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/moduleinfo/:id/enrollments/:user_id", app.requirePermission("movies:read", app.dropEnrollmentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moduleinfo/:id/grades", app.requirePermission("results:read", app.getGradeDistributionHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/departmentinfo", app.requirePermission("movies:read", app.CreateDepInfoHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/departmentinfo/:id", app.requirePermission("movies:read", app.GetDepInfoHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/departmentinfo", app.requirePermission("movies:read", app.GetAllDepInfosHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/departmentinfo/:id", app.requirePermission("movies:read", app.EditDepInfoHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/departmentinfo/:id", app.requirePermission("movies:read", app.DeleteDepInfoHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/departmentinfo/:id/modules", app.requirePermission("movies:read", app.getDepartmentModulesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/departmentinfo/:id/modules", app.requirePermission("movies:read", app.addDepartmentModulesHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/departmentinfo/:id/modules/:module_id", app.requirePermission("movies:read", app.removeDepartmentModuleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/departmentinfo/:id/staff", app.requirePermission("movies:read", app.getDepartmentStaffHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/departmentinfo/:id/staff", app.requirePermission("movies:read", app.addDepartmentStaffHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/departmentinfo/:id/staff/:user_id", app.requirePermission("movies:read", app.removeDepartmentStaffHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/departmentinfo/:id/subtree", app.requirePermission("movies:read", app.getDepSubtreeHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/departmentinfo/:id/ancestors", app.requirePermission("movies:read", app.getDepAncestorsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/departmentinfo/:id/parent", app.requirePermission("movies:read", app.moveDepHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/programs", app.requirePermission("movies:read", app.createProgramHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/programs", app.requirePermission("movies:read", app.getAllProgramsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/programs/:id", app.requirePermission("movies:read", app.getProgramHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/programs/:id/view", app.requirePermission("movies:read", app.getProgramViewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/programs/:id", app.requirePermission("movies:read", app.editProgramHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/programs/:id", app.requirePermission("movies:read", app.deleteProgramHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/results", app.requirePermission("results:write", app.createExamResultHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/results/:id", app.requirePermission("results:read", app.getExamResultHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/results/:id", app.requirePermission("results:write", app.editExamResultHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/results/:id", app.requirePermission("results:write", app.deleteExamResultHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/gradingscales", app.requirePermission("results:read", app.getGradingScalesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/gradingscales", app.requirePermission("results:write", app.replaceGradingScaleHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/exams", app.requirePermission("movies:read", app.createExamSessionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/exams", app.requirePermission("movies:read", app.getAllExamSessionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/exams/:id", app.requirePermission("movies:read", app.getExamSessionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/exams/:id", app.requirePermission("movies:read", app.editExamSessionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/exams/:id", app.requirePermission("movies:read", app.deleteExamSessionHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/timetables", app.requirePermission("movies:read", app.generateTimetableHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/timetables", app.requirePermission("movies:read", app.getAllTimetablesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/timetables/:id", app.requirePermission("movies:read", app.getTimetableHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/timetables/:id/publish", app.requirePermission("movies:read", app.publishTimetableHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/examtypes", app.requirePermission("movies:read", app.getAllExamTypesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/examtypes/:code", app.requirePermission("movies:read", app.getExamTypeHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/examtypes", app.requirePermission("examtypes:write", app.createExamTypeHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/examtypes/:code", app.requirePermission("examtypes:write", app.editExamTypeHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/examtypes/:code", app.requirePermission("examtypes:write", app.deleteExamTypeHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/import/moduleinfo", app.requirePermission("movies:read", app.importModuleInfosHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/import/users", app.requirePermission("users:admin", app.importUserInfosHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/export/moduleinfo", app.requirePermission("movies:read", app.exportModuleInfosHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/export/departmentinfo", app.requirePermission("movies:read", app.exportDepartmentInfosHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/export/users", app.requirePermission("movies:read", app.exportUserInfosHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/trash/moduleinfo", app.requirePermission("movies:read", app.getTrashedModuleInfosHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/trash/moduleinfo/:id/restore", app.requirePermission("movies:read", app.restoreModuleInfoHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/trash/users", app.requirePermission("movies:read", app.getTrashedUserInfosHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/trash/users/:id/restore", app.requirePermission("movies:read", app.restoreUserInfoHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/search", app.requirePermission("movies:read", app.searchHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/stats/modules/exam-types", app.requirePermission("movies:read", app.getExamTypeStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/modules/durations", app.requirePermission("movies:read", app.getDurationStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/departments/staff-sizes", app.requirePermission("movies:read", app.getStaffSizeStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/users/registrations", app.requirePermission("movies:read", app.getRegistrationStatsHandler))
 
 	//USER
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
@@ -25,5 +103,7 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/users", app.requirePermission("movies:read", app.getAllUserInfos))
 	router.HandlerFunc(http.MethodPatch, "/v1/users/:id", app.requirePermission("movies:read", app.editUserInfoHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/users/:id", app.requirePermission("movies:read", app.deleteUserInfoHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/enrollments", app.requirePermission("movies:read", app.getUserEnrollmentsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/transcript", app.requirePermission("results:read", app.getTranscriptHandler))
 	return (app.recoverPanic(app.rateLimit(app.authenticate(router))))
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query   string
+		Types   []string
+		Filters data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Query = app.readString(qs, "q", "")
+	input.Types = app.readList(qs, "types")
+	if input.Types == nil {
+		input.Types = data.SearchTypes
+	}
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	// Results are always ranked best first.
+	input.Filters.Sort = "rank"
+	input.Filters.SortSafeList = []string{"rank"}
+
+	data.ValidateSearch(v, input.Query, input.Types)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	results, metadata, err := app.models.Search.Search(input.Query, input.Types, showEmails, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
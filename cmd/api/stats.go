@@ -0,0 +1,304 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statsCacheSize bounds the number of distinct stats requests (endpoint and date
+// range) kept in the cache. Beyond it the least recently used entry is evicted.
+const statsCacheSize = 100
+
+// statsCache holds computed statistics by request so that they are not recomputed
+// on every request. refresh recomputes the entries that were asked for since the
+// previous refresh and drops the rest, so clients see figures at most one refresh
+// interval old.
+type statsCache struct {
+	mu sync.Mutex
+	// entries holds the elements of lru, whose values are *statsEntry, most
+	// recently used first.
+	entries map[string]*list.Element
+	lru     *list.List
+	loading map[string]*statsLoad
+}
+
+type statsEntry struct {
+	key         string
+	load        func() (any, error)
+	value       any
+	generatedAt time.Time
+	used        bool
+}
+
+// statsLoad is a computation in progress. Concurrent misses on its key wait for it
+// instead of running the same aggregate again.
+type statsLoad struct {
+	done        chan struct{}
+	value       any
+	generatedAt time.Time
+	err         error
+}
+
+var errStatsLoadAborted = errors.New("computing the statistics was aborted")
+
+func newStatsCache() *statsCache {
+	return &statsCache{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		loading: make(map[string]*statsLoad),
+	}
+}
+
+// get returns the cached value for key, computing it with load on a miss.
+func (c *statsCache) get(key string, load func() (any, error)) (any, time.Time, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		entry := element.Value.(*statsEntry)
+		entry.used = true
+		value, generatedAt := entry.value, entry.generatedAt
+		c.mu.Unlock()
+		return value, generatedAt, nil
+	}
+	if l, ok := c.loading[key]; ok {
+		c.mu.Unlock()
+		<-l.done
+		return l.value, l.generatedAt, l.err
+	}
+	// Should load panic, the waiters get errStatsLoadAborted rather than a nil value.
+	l := &statsLoad{done: make(chan struct{}), err: errStatsLoadAborted}
+	c.loading[key] = l
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.loading, key)
+		if l.err == nil {
+			c.entries[key] = c.lru.PushFront(&statsEntry{key: key, load: load, value: l.value, generatedAt: l.generatedAt, used: true})
+			if c.lru.Len() > statsCacheSize {
+				oldest := c.lru.Remove(c.lru.Back()).(*statsEntry)
+				delete(c.entries, oldest.key)
+			}
+		}
+		c.mu.Unlock()
+		close(l.done)
+	}()
+	l.value, l.err = load()
+	l.generatedAt = time.Now().UTC()
+	return l.value, l.generatedAt, l.err
+}
+
+// refresh recomputes every entry used since the last refresh and evicts the others.
+// The queries run without the lock held, so requests keep being answered from the
+// old values meanwhile.
+func (c *statsCache) refresh() []error {
+	c.mu.Lock()
+	var stale []*list.Element
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*statsEntry)
+		if !entry.used {
+			c.lru.Remove(element)
+			delete(c.entries, entry.key)
+		} else {
+			entry.used = false
+			stale = append(stale, element)
+		}
+		element = next
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, element := range stale {
+		entry := element.Value.(*statsEntry)
+		value, err := entry.load()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("refreshing %s: %w", entry.key, err))
+			continue
+		}
+		c.mu.Lock()
+		if current, ok := c.entries[entry.key]; ok && current == element {
+			entry.value = value
+			entry.generatedAt = time.Now().UTC()
+		}
+		c.mu.Unlock()
+	}
+	return errs
+}
+
+// refreshStats refreshes the stats cache at the configured interval for the lifetime
+// of the process.
+func (app *application) refreshStats() {
+	if app.config.stats.refreshInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(app.config.stats.refreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, err := range app.stats.refresh() {
+			app.logger.PrintError(err, nil)
+		}
+	}
+}
+
+const (
+	statsFormatJSON = "json"
+	statsFormatCSV  = "csv"
+)
+
+// statsInput is the date range and output format the stats endpoints accept. From
+// is inclusive and To exclusive, except that a plain date as to includes that whole
+// day, so from=2026-01-31&to=2026-01-31 covers January 31st.
+type statsInput struct {
+	From   time.Time
+	To     time.Time
+	Format string
+}
+
+// key identifies a request for the stats called name in the cache. The bounds are
+// keyed as instants in UTC, so a date and the timestamp of its midnight share an
+// entry; a client varying them only churns the least recently used entries.
+func (in statsInput) key(name string) string {
+	return fmt.Sprintf("%s?from=%s&to=%s", name, in.From.UTC().Format(time.RFC3339Nano), in.To.UTC().Format(time.RFC3339Nano))
+}
+
+// readStatsInput reads the from, to and format query string values. It sends the
+// validation response itself and returns false if they are invalid.
+func (app *application) readStatsInput(w http.ResponseWriter, r *http.Request) (statsInput, bool) {
+	var input statsInput
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.From = app.readTime(qs, "from", v)
+	input.To = app.readTime(qs, "to", v)
+	if _, err := time.Parse(time.DateOnly, qs.Get("to")); err == nil {
+		input.To = input.To.AddDate(0, 0, 1)
+	}
+	input.Format = app.readString(qs, "format", statsFormatJSON)
+
+	if !input.From.IsZero() && !input.To.IsZero() {
+		v.Check(input.To.After(input.From), "to", "must be after from")
+	}
+	v.Check(validator.PermittedValue(input.Format, statsFormatJSON, statsFormatCSV), "format", "must be json or csv")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return input, false
+	}
+	return input, true
+}
+
+// writeStats sends value as JSON or, for format=csv, sends the rows built from it
+// under columns.
+func (app *application) writeStats(w http.ResponseWriter, r *http.Request, input statsInput, name string, value any, generatedAt time.Time, columns []string, rows [][]string) {
+	if input.Format == statsFormatCSV {
+		e := app.newExporter(w, exportFormatCSV, "stats-"+name, columns)
+		var err error
+		for _, row := range rows {
+			if err = e.write(nil, row); err != nil {
+				break
+			}
+		}
+		app.finishExport(r, w, e, err)
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"stats": value, "generated_at": generatedAt}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getExamTypeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	input, ok := app.readStatsInput(w, r)
+	if !ok {
+		return
+	}
+	value, generatedAt, err := app.stats.get(input.key("exam-types"), func() (any, error) {
+		return app.models.Stats.ModulesByExamType(input.From, input.To)
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	counts := value.([]*data.ExamTypeCount)
+	rows := make([][]string, len(counts))
+	for i, count := range counts {
+		rows[i] = []string{count.ExamType, csvText(count.DisplayName), strconv.Itoa(count.Modules)}
+	}
+	app.writeStats(w, r, input, "exam-types", counts, generatedAt, []string{"exam_type", "display_name", "modules"}, rows)
+}
+func (app *application) getDurationStatsHandler(w http.ResponseWriter, r *http.Request) {
+	input, ok := app.readStatsInput(w, r)
+	if !ok {
+		return
+	}
+	value, generatedAt, err := app.stats.get(input.key("durations"), func() (any, error) {
+		return app.models.Stats.ModuleDurations(input.From, input.To)
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// The CSV holds the histogram; the average follows from it.
+	stats := value.(*data.DurationStats)
+	rows := make([][]string, len(stats.Histogram))
+	for i, bucket := range stats.Histogram {
+		rows[i] = []string{strconv.Itoa(int(bucket.ModuleDuration)), strconv.Itoa(bucket.Modules)}
+	}
+	app.writeStats(w, r, input, "durations", stats, generatedAt, []string{"module_duration", "modules"}, rows)
+}
+
+// getStaffSizeStatsHandler reports units by their current staff, so it takes no date
+// range.
+func (app *application) getStaffSizeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	input, ok := app.readStatsInput(w, r)
+	if !ok {
+		return
+	}
+	if !input.From.IsZero() || !input.To.IsZero() {
+		app.failedValidationResponse(w, r, map[string]string{"from": "staff sizes are always current and take no date range"})
+		return
+	}
+	value, generatedAt, err := app.stats.get(input.key("staff-sizes"), func() (any, error) {
+		return app.models.Stats.DepartmentsByStaffSize()
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	buckets := value.([]*data.StaffSizeBucket)
+	rows := make([][]string, len(buckets))
+	for i, bucket := range buckets {
+		maxStaff := ""
+		if bucket.MaxStaff > 0 {
+			maxStaff = strconv.Itoa(bucket.MaxStaff)
+		}
+		rows[i] = []string{strconv.Itoa(bucket.MinStaff), maxStaff, strconv.Itoa(bucket.Departments)}
+	}
+	app.writeStats(w, r, input, "staff-sizes", buckets, generatedAt, []string{"min_staff", "max_staff", "departments"}, rows)
+}
+func (app *application) getRegistrationStatsHandler(w http.ResponseWriter, r *http.Request) {
+	input, ok := app.readStatsInput(w, r)
+	if !ok {
+		return
+	}
+	value, generatedAt, err := app.stats.get(input.key("registrations"), func() (any, error) {
+		return app.models.Stats.WeeklyRegistrations(input.From, input.To)
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	weeks := value.([]*data.WeeklyRegistrations)
+	rows := make([][]string, len(weeks))
+	for i, week := range weeks {
+		rows[i] = []string{week.Week.Format("2006-01-02"), strconv.Itoa(week.Registered), strconv.Itoa(week.Activated), strconv.FormatFloat(week.ActivationRate, 'f', 4, 64)}
+	}
+	app.writeStats(w, r, input, "registrations", weeks, generatedAt, []string{"week", "registered", "activated", "activation_rate"}, rows)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+)
+
+// readSuggest reads the prefix and limit shared by the suggest endpoints. It sends
+// the validation response itself and returns false if they are invalid.
+func (app *application) readSuggest(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	prefix := app.readString(qs, "prefix", "")
+	limit := app.readInt(qs, "limit", 10, v)
+
+	if data.ValidateSuggest(v, prefix, limit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return "", 0, false
+	}
+	return prefix, limit, true
+}
+func (app *application) suggestModuleInfosHandler(w http.ResponseWriter, r *http.Request) {
+	prefix, limit, ok := app.readSuggest(w, r)
+	if !ok {
+		return
+	}
+	suggestions, err := app.models.ModuleInfoModel.Suggest(prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) suggestUserInfosHandler(w http.ResponseWriter, r *http.Request) {
+	prefix, limit, ok := app.readSuggest(w, r)
+	if !ok {
+		return
+	}
+	suggestions, err := app.models.UserInfoModel.Suggest(prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/timetable"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+	"time"
+)
+
+// timetableSolveTimeout bounds the backtracking search for one timetable. If it runs
+// out, the timetable is built by the greedy pass instead.
+const timetableSolveTimeout = 5 * time.Second
+
+// generateTimetableHandler runs the timetable solver and stores the result as the next
+// draft version of the named timetable. The number of weekly lectures of a module is
+// its module_duration divided by credits_per_lecture, rounded up.
+func (app *application) generateTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name              string           `json:"timetable_name"`
+		ModuleIDs         []int64          `json:"module_ids"`
+		CreditsPerLecture int32            `json:"credits_per_lecture"`
+		Slots             []timetable.Slot `json:"slots"`
+		Rooms             []timetable.Room `json:"rooms"`
+		Teachers          []struct {
+			UserID    int64   `json:"user_id"`
+			ModuleIDs []int64 `json:"module_ids"`
+			Available []int   `json:"available"`
+		} `json:"teachers"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.CreditsPerLecture == 0 {
+		input.CreditsPerLecture = 3
+	}
+	v := validator.New()
+	v.Check(input.Name != "", "timetable_name", "must be provided")
+	v.Check(len(input.Name) <= 255, "timetable_name", "must not be more than 255 bytes long")
+	v.Check(input.CreditsPerLecture > 0, "credits_per_lecture", "must be greater than zero")
+	if data.ValidateModuleIDs(v, input.ModuleIDs); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	moduleInfos, err := app.models.Timetables.LoadModules(input.ModuleIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(moduleInfos) != len(input.ModuleIDs) {
+		v.AddError("module_ids", "must only reference existing modules")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	clashes, err := app.models.Timetables.Clashes(input.ModuleIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	teachersByModule := make(map[int64][]int64)
+	problem := timetable.Problem{Slots: input.Slots, Rooms: input.Rooms, Clashes: clashes}
+	for _, teacher := range input.Teachers {
+		problem.Teachers = append(problem.Teachers, timetable.Teacher{ID: teacher.UserID, Available: teacher.Available})
+		for _, moduleID := range teacher.ModuleIDs {
+			teachersByModule[moduleID] = append(teachersByModule[moduleID], teacher.UserID)
+		}
+	}
+	for _, moduleInfo := range moduleInfos {
+		problem.Modules = append(problem.Modules, timetable.Module{
+			ID:       moduleInfo.ID,
+			Name:     moduleInfo.ModuleName,
+			Lectures: int((moduleInfo.ModuleDuration + input.CreditsPerLecture - 1) / input.CreditsPerLecture),
+			Capacity: int(moduleInfo.Capacity),
+			Teachers: teachersByModule[moduleInfo.ID],
+		})
+	}
+	if data.ValidateTimetableProblem(v, problem); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timetableSolveTimeout)
+	defer cancel()
+	solution := timetable.Solve(ctx, problem, 0)
+	t := &data.Timetable{
+		Name:        input.Name,
+		Complete:    solution.Complete,
+		Unsatisfied: solution.Unsatisfied,
+		Entries:     []data.TimetableEntry{},
+	}
+	for _, assignment := range solution.Assignments {
+		slot := problem.Slots[assignment.Slot]
+		t.Entries = append(t.Entries, data.TimetableEntry{
+			ModuleID:  assignment.ModuleID,
+			Lecture:   assignment.Lecture,
+			TeacherID: assignment.TeacherID,
+			Room:      assignment.Room,
+			Day:       slot.Day,
+			StartTime: slot.Start,
+			EndTime:   slot.End,
+		})
+	}
+	err = app.models.Timetables.Insert(t)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("teachers", "user_id must reference existing users")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/timetables/%d", t.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"timetable": t}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	t, err := app.models.Timetables.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"timetable": t}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getAllTimetablesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name    string
+		Status  string
+		Filters data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "timetable_name", "")
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafeList = []string{"timetable_name", "-timetable_name", "version", "-version", "id", "-id"}
+
+	if input.Status != "" {
+		v.Check(validator.PermittedValue(input.Status, data.TimetableDraft, data.TimetablePublished, data.TimetableArchived), "status", "invalid status value")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	timetables, metadata, err := app.models.Timetables.GetAll(input.Name, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"timetables": timetables, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) publishTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Timetables.Publish(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	t, err := app.models.Timetables.Get(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"timetable": t}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
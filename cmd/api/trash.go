@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (app *application) getTrashedModuleInfosHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Filters data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filters.SortSafeList = []string{"deleted_at", "-deleted_at", "module_name", "-module_name", "id", "-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	moduleInfos, metadata, err := app.models.ModuleInfoModel.GetDeleted(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"module infos": moduleInfos, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) restoreModuleInfoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	moduleInfo, err := app.models.ModuleInfoModel.Restore(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"module info": moduleInfo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) getTrashedUserInfosHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Filters data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filters.SortSafeList = []string{"deleted_at", "-deleted_at", "fname", "-fname", "sname", "-sname", "id", "-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	userInfos, metadata, err := app.models.UserInfoModel.GetDeleted(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !showEmails {
+		for _, userInfo := range userInfos {
+			userInfo.Email = ""
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"user infos": userInfos, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+func (app *application) restoreUserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	userInfo, err := app.models.UserInfoModel.Restore(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.hideEmail(r, userInfo)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"user info": userInfo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTrash permanently deletes records that have been in the trash for longer than
+// the configured retention period. It runs for the lifetime of the process.
+func (app *application) purgeTrash() {
+	if app.config.trash.retention <= 0 || app.config.trash.purgeInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().Add(-app.config.trash.retention)
+
+		// The blobs of purged attachments are only deleted once the purge has
+		// committed, so a module restored meanwhile keeps its files.
+		modules, keys, err := app.models.ModuleInfoModel.PurgeDeleted(cutoff)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+		for _, key := range keys {
+			err := app.blobs.Delete(context.Background(), key)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"storage_key": key})
+			}
+		}
+		users, err := app.models.UserInfoModel.PurgeDeleted(cutoff)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+		if modules > 0 || users > 0 {
+			app.logger.PrintInfo("purged expired records from trash", map[string]string{
+				"modules": strconv.FormatInt(modules, 10),
+				"users":   strconv.FormatInt(users, 10),
+			})
+		}
+		<-ticker.C
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"errors"
+	"github.com/julienschmidt/httprouter"
 	"golangHW.darkhanomirbay/internal/data"
+	"golangHW.darkhanomirbay/internal/filter"
 	"golangHW.darkhanomirbay/internal/validator"
 	"net/http"
 	"time"
@@ -88,9 +90,11 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the plaintext activation token from the request body.
+	// Parse the plaintext activation token from the request body. Users imported
+	// without a password choose one here.
 	var input struct {
-		TokenPlaintext string `json:"token"`
+		TokenPlaintext string  `json:"token"`
+		Password       *string `json:"password"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -99,7 +103,11 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 	// Validate the plaintext token provided by the client.
 	v := validator.New()
-	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	if input.Password != nil {
+		data.ValidatePasswordPlaintext(v, *input.Password)
+	}
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -117,6 +125,17 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
+	if input.Password != nil {
+		err = user.Password.Set(*input.Password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else if !user.Password.Usable() {
+		v.AddError("password", "must be provided to activate an account created without one")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 	// Update the user's activation status.
 	user.Activated = true
 	// Save the updated user record in our database, checking for any edit conflicts in
@@ -144,10 +163,23 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		app.serverErrorResponse(w, r, err)
 	}
 }
+// getUserInfoHandler also serves GET /v1/users/suggest, which httprouter cannot
+// hold as a static route next to :id.
 func (app *application) getUserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "suggest" {
+		app.suggestUserInfosHandler(w, r)
+		return
+	}
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+	fields := app.readFields(r.URL.Query(), &data.UserInfo{}, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 	userInfo, err := app.models.UserInfoModel.Get(id)
 	if err != nil {
@@ -160,7 +192,20 @@ func (app *application) getUserInfoHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"user info": userInfo}, nil)
+	if app.notModified(w, r, int32(userInfo.Version)) {
+		return
+	}
+	err = app.hideEmail(r, userInfo)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	shaped, err := shape(userInfo, fields, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"user info": shaped}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -171,15 +216,36 @@ func (app *application) deleteUserInfoHandler(w http.ResponseWriter, r *http.Req
 		app.notFoundResponse(w, r)
 		return
 	}
-	err = app.models.UserInfoModel.Delete(id)
+	// With If-Match the delete only goes ahead if the user is still at the version
+	// the client last saw.
+	var version int
+	if r.Header.Get("If-Match") != "" {
+		userInfo, err := app.models.UserInfoModel.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !app.checkIfMatch(w, r, int32(userInfo.Version)) {
+			return
+		}
+		version = userInfo.Version
+	}
+	err = app.models.UserInfoModel.Delete(id, version)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.versionConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
-
+		return
 	}
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user info successfully deleted"}, nil)
 	if err != nil {
@@ -187,10 +253,50 @@ func (app *application) deleteUserInfoHandler(w http.ResponseWriter, r *http.Req
 	}
 
 }
+
+// userInfoSortSafeList is shared by the list and export handlers.
+var userInfoSortSafeList = []string{"fname", "-fname", "sname", "-sname", "id", "-id"}
+
+// canSeeEmails reports whether the user making the request may see other users'
+// email addresses, which takes the users:admin permission.
+func (app *application) canSeeEmails(r *http.Request) (bool, error) {
+	permissions, err := app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include("users:admin"), nil
+}
+
+// userInfoFilterFields are the fields users can be filtered on: email only by
+// callers allowed to see it.
+func userInfoFilterFields(showEmails bool) filter.Fields {
+	if showEmails {
+		return data.UserInfoFilterFields
+	}
+	return data.UserInfoFilterFieldsWithoutEmail
+}
+
+// hideEmail clears the email address of a user shown on its own, unless it is the
+// caller's own or the caller may see addresses.
+func (app *application) hideEmail(r *http.Request, user *data.UserInfo) error {
+	if user.ID == app.contextGetUser(r).ID {
+		return nil
+	}
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		return err
+	}
+	if !showEmails {
+		user.Email = ""
+	}
+	return nil
+}
+
 func (app *application) getAllUserInfos(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Fname   string
 		Sname   string
+		Within  int64
 		Filters data.Filters
 	}
 	v := validator.New()
@@ -198,21 +304,49 @@ func (app *application) getAllUserInfos(w http.ResponseWriter, r *http.Request)
 
 	input.Fname = app.readString(qs, "fname", "")
 	input.Sname = app.readString(qs, "sname", "")
+	input.Within = int64(app.readInt(qs, "within", 0, v))
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"fname", "-fname", "sname", "-sname", "id", "-id"}
+	input.Filters.SortSafeList = userInfoSortSafeList
+	input.Filters.Filter = app.readString(qs, "filter", "")
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.SkipCount = !app.readBool(qs, "count", input.Filters.Cursor == "", v)
+	fields := app.readFields(qs, &data.UserInfo{}, v)
 
+	showEmails, err := app.canSeeEmails(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	input.Filters.FilterFields = userInfoFilterFields(showEmails)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	userInfo, metadata, err := app.models.UserInfoModel.GetAll(input.Fname, input.Sname, input.Filters)
+	userInfo, metadata, err := app.models.UserInfoModel.GetAll(input.Fname, input.Sname, input.Within, input.Filters)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "is invalid")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"user infos": userInfo, "metadata": metadata}, nil)
+	shaped := make([]any, len(userInfo))
+	for i := range userInfo {
+		if !showEmails {
+			userInfo[i].Email = ""
+		}
+		shaped[i], err = shape(userInfo[i], fields, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"user infos": shaped, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -231,18 +365,32 @@ func (app *application) editUserInfoHandler(w http.ResponseWriter, r *http.Reque
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
+		return
+	}
+	if !app.checkIfMatch(w, r, int32(userInfo.Version)) {
+		return
 	}
 	var input struct {
 		Fname    *string `json:"fname"`
 		Sname    *string `json:"sname"`
 		Email    *string `json:"email"`
 		Role     *string `json:"role"`
-		Password string  `json:"password"`
-	}
-
-	err = app.readJSON(w, r, &input)
+		Password *string `json:"password"`
+	}
+	// Patches are applied to the user under the same names the request uses. The
+	// password is write-only, so it can be set but never tested or removed.
+	current := envelope{
+		"id":        userInfo.ID,
+		"fname":     userInfo.Name,
+		"sname":     userInfo.Surname,
+		"email":     userInfo.Email,
+		"role":      userInfo.Role,
+		"activated": userInfo.Activated,
+	}
+	err = app.readPatch(w, r, current, &input)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.patchErrorResponse(w, r, err)
+		return
 	}
 	if input.Fname != nil {
 		userInfo.Name = *input.Fname
@@ -257,26 +405,37 @@ func (app *application) editUserInfoHandler(w http.ResponseWriter, r *http.Reque
 		userInfo.Role = *input.Role
 	}
 
-	err = userInfo.Password.Set(input.Password)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
+	if input.Password != nil {
+		err = userInfo.Password.Set(*input.Password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 	}
 	v := validator.New()
 	if data.ValidateUser(v, userInfo); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 	err = app.models.UserInfoModel.Update(userInfo)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
-			app.editConflicResponse(w, r)
+			app.versionConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 
 		}
+		return
+	}
+	err = app.hideEmail(r, userInfo)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"updated user info": userInfo}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(int32(userInfo.Version)))
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated user info": userInfo}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,33 @@
+// Package blobstore stores the contents of uploaded files. The database keeps the
+// metadata and refers to each blob by an opaque key chosen by the caller.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store is implemented by every blob backend.
+type Store interface {
+	// Put writes the contents of r under key and returns the number of bytes written.
+	// A blob only becomes visible once it has been written completely.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns the blob for reading. The caller must close it.
+	Open(ctx context.Context, key string) (Blob, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is an open blob. It is seekable so it can serve HTTP Range requests.
+type Blob interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"golangHW.darkhanomirbay/internal/validator"
+	"mime"
+	"time"
+)
+
+// Attachment is the metadata of a file attached to a module. The contents live in
+// the blob store under StorageKey.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ModuleID    int64     `json:"module_id"`
+	Kind        string    `json:"kind"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	StorageKey  string    `json:"-"`
+	UploadedBy  int64     `json:"uploaded_by"`
+}
+
+var AttachmentKinds = []string{"syllabus", "reading_list", "exam_spec", "other"}
+
+// AttachmentContentTypes lists the media types accepted for upload, as detected from
+// the file contents rather than taken from the client. Office documents sniff as zip
+// archives.
+var AttachmentContentTypes = []string{
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+	"image/png",
+	"image/jpeg",
+}
+
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+func ValidateAttachment(v *validator.Validator, attachment *Attachment) {
+	v.Check(validator.PermittedValue(attachment.Kind, AttachmentKinds...), "kind", "invalid attachment kind")
+	v.Check(attachment.FileName != "", "file", "must have a file name")
+	v.Check(len(attachment.FileName) <= 255, "file", "file name must not be more than 255 bytes long")
+	ValidateAttachmentContentType(v, attachment.ContentType)
+}
+func ValidateAttachmentContentType(v *validator.Validator, contentType string) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	v.Check(err == nil && validator.PermittedValue(mediaType, AttachmentContentTypes...), "file", "unsupported file type")
+}
+
+func (m AttachmentModel) Insert(attachment *Attachment) error {
+	query := `
+INSERT INTO module_attachments (module_id, kind, file_name, content_type, size_bytes, checksum, storage_key, uploaded_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0))
+RETURNING id, created_at`
+	args := []any{attachment.ModuleID, attachment.Kind, attachment.FileName, attachment.ContentType, attachment.Size, attachment.Checksum, attachment.StorageKey, attachment.UploadedBy}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m AttachmentModel) Get(moduleID, id int64) (*Attachment, error) {
+	if moduleID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+SELECT id, created_at, module_id, kind, file_name, content_type, size_bytes, checksum, storage_key, COALESCE(uploaded_by, 0)
+FROM module_attachments
+WHERE module_id = $1 AND id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var attachment Attachment
+	err := m.DB.QueryRowContext(ctx, query, moduleID, id).Scan(&attachment.ID, &attachment.CreatedAt, &attachment.ModuleID, &attachment.Kind, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.Checksum, &attachment.StorageKey, &attachment.UploadedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &attachment, nil
+}
+func (m AttachmentModel) GetForModule(moduleID int64) ([]*Attachment, error) {
+	query := `
+SELECT id, created_at, module_id, kind, file_name, content_type, size_bytes, checksum, storage_key, COALESCE(uploaded_by, 0)
+FROM module_attachments
+WHERE module_id = $1
+ORDER BY kind, created_at, id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := []*Attachment{}
+	for rows.Next() {
+		var attachment Attachment
+		err := rows.Scan(&attachment.ID, &attachment.CreatedAt, &attachment.ModuleID, &attachment.Kind, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.Checksum, &attachment.StorageKey, &attachment.UploadedBy)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, &attachment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Delete removes the attachment row and returns its storage key so the caller can
+// remove the blob once the row is gone.
+func (m AttachmentModel) Delete(moduleID, id int64) (string, error) {
+	if moduleID < 1 || id < 1 {
+		return "", ErrRecordNotFound
+	}
+	query := `DELETE FROM module_attachments WHERE module_id = $1 AND id = $2 RETURNING storage_key`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var key string
+	err := m.DB.QueryRowContext(ctx, query, moduleID, id).Scan(&key)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+	return key, nil
+}
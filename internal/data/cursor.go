@@ -0,0 +1,127 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// ErrInvalidCursor is returned by list queries given a cursor whose position the
+// database cannot compare against the sort column, which only happens if a client
+// has tampered with it.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the position after which a keyset-paginated listing continues: the
+// sort it was issued for, and the sort value and id of the last row returned.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	if b, ok := c.Value.([]byte); ok {
+		c.Value = string(b)
+	}
+	js, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	err = dec.Decode(&c)
+	if err != nil || c.ID < 1 {
+		return c, ErrInvalidCursor
+	}
+	switch c.Value.(type) {
+	case string, json.Number:
+	default:
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// countColumn is the first select column of a list query: the total number of
+// matching rows, or 0 when the client has asked not to count them.
+func (f Filters) countColumn() string {
+	if f.SkipCount {
+		return "0"
+	}
+	return "count(*) OVER()"
+}
+
+// pageQuery turns inner, a query selecting every matching row with countColumn
+// first and an id column, into the query for one page. The page is sorted and
+// limited outside inner so that sort columns computed in its select list can be
+// compared against a cursor, and so that the total is counted before the cursor
+// narrows the rows down. args are inner's arguments; the page's own are appended.
+//
+// The rows of the page start with the value of the sort column, which the caller
+// passes back to pageMetadata for the last row, followed by inner's columns.
+func (f Filters) pageQuery(inner string, args ...any) (string, []any) {
+	column, direction := f.sortColumn(), f.sortDirection()
+	where := ""
+	offset := f.offset()
+	if f.Cursor != "" {
+		// ValidateFilters has already rejected cursors that do not decode.
+		c, _ := decodeCursor(f.Cursor)
+		comparison := ">"
+		if direction == "DESC" {
+			comparison = "<"
+		}
+		if column == "id" {
+			args = append(args, c.ID)
+			where = fmt.Sprintf("WHERE list.id %s $%d", comparison, len(args))
+		} else {
+			args = append(args, c.Value, c.ID)
+			where = fmt.Sprintf("WHERE (list.%[1]s %[2]s $%[3]d OR (list.%[1]s = $%[3]d AND list.id > $%[4]d))", column, comparison, len(args)-1, len(args))
+		}
+		offset = 0
+	}
+	args = append(args, f.limit(), offset)
+	query := fmt.Sprintf(`SELECT list.%[1]s, list.* FROM (%[2]s) AS list
+	%[3]s
+	ORDER BY list.%[1]s %[4]s, list.id ASC
+	LIMIT $%[5]d OFFSET $%[6]d`, column, inner, where, direction, len(args)-1, len(args))
+	return query, args
+}
+
+// pageQueryError replaces the error the database gives for a cursor value that
+// does not fit the sort column with ErrInvalidCursor.
+func (f Filters) pageQueryError(err error) error {
+	var pqErr *pq.Error
+	if f.Cursor != "" && errors.As(err, &pqErr) && pqErr.Code.Class() == "22" {
+		return ErrInvalidCursor
+	}
+	return err
+}
+
+// pageMetadata is the metadata for a page of rows rows. lastSortValue and lastID
+// belong to the last row and become the next cursor when the page is full.
+func (f Filters) pageMetadata(totalRecords, rows int, lastSortValue any, lastID int64) Metadata {
+	var metadata Metadata
+	switch {
+	case f.Cursor != "":
+		metadata = Metadata{PageSize: f.PageSize, TotalRecords: totalRecords}
+	case f.SkipCount:
+		metadata = Metadata{CurrentPage: f.Page, PageSize: f.PageSize, FirstPage: 1}
+	default:
+		metadata = calculateMetadata(totalRecords, f.Page, f.PageSize)
+	}
+	if rows > 0 && rows == f.PageSize {
+		metadata.NextCursor = encodeCursor(cursor{Sort: f.Sort, Value: lastSortValue, ID: lastID})
+	}
+	return metadata
+}
@@ -4,46 +4,106 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"golangHW.darkhanomirbay/internal/filter"
 	"golangHW.darkhanomirbay/internal/validator"
 	"time"
 )
 
 type DepartmentInfo struct {
-	ID                 int64  `json:"id"`
-	DepartmentName     string `json:"department_name"`
-	StaffQuantity      int32  `json:"staff_quantity"`
-	DepartmentDirector string `json:"department_director"`
-	ModuleID           int64  `json:"module_id"`
+	ID             int64  `json:"id"`
+	DepartmentName string `json:"department_name"`
+	StaffQuantity  int32  `json:"staff_quantity"`
+	DirectorID     int64  `json:"director_id"`
+	ParentID       int64  `json:"parent_id"`
+	UnitType       string `json:"unit_type"`
+	Version        int32  `json:"version"`
 }
+
+// DepartmentNode is a unit in a subtree or ancestor path together with its distance
+// from the unit the query started at.
+type DepartmentNode struct {
+	*DepartmentInfo
+	Depth int `json:"depth"`
+}
+
+var (
+	ErrHierarchyLoop   = errors.New("hierarchy loop")
+	ErrUnitHasChildren = errors.New("unit has children")
+)
+
+// UnitTypes lists the kinds of organisational unit. A ParentID of zero marks a top
+// level unit.
+var UnitTypes = []string{"faculty", "department", "unit"}
+
+// staffQuantity counts the department's staff memberships, leaving out users in the
+// trash as the staff listings do.
+const staffQuantity = `(SELECT count(*) FROM department_staff
+	INNER JOIN user_info ON user_info.id = department_staff.user_id
+	WHERE department_staff.department_id = department_info.id AND user_info.deleted_at IS NULL)`
+
+// staffQuantityColumn computes staff_quantity from the department's staff memberships
+// so it can never drift from the department_staff table.
+const staffQuantityColumn = staffQuantity + ` AS staff_quantity`
+
+// departmentColumns is the select list matching DepartmentInfo.scanDest. Every
+// column is named so the list can be paged from a subquery.
+const departmentColumns = `department_info.id,department_info.department_name,` + staffQuantityColumn + `,COALESCE(department_info.director_id, 0) AS director_id,COALESCE(department_info.parent_id, 0) AS parent_id,department_info.unit_type,department_info.version`
+
+func (d *DepartmentInfo) scanDest() []any {
+	return []any{&d.ID, &d.DepartmentName, &d.StaffQuantity, &d.DirectorID, &d.ParentID, &d.UnitType, &d.Version}
+}
+
+// WithinUnit returns a subquery selecting the ids of the unit bound to param and of
+// every unit nested below it.
+func WithinUnit(param string) string {
+	return `WITH RECURSIVE within_unit(id) AS (
+		SELECT id FROM department_info WHERE id = ` + param + `
+		UNION
+		SELECT department_info.id FROM department_info INNER JOIN within_unit ON department_info.parent_id = within_unit.id
+	) SELECT id FROM within_unit`
+}
+
 type DepartmentInfoModel struct {
 	DB *sql.DB
 }
 
+// ValidateDepartmentInfo checks a unit as it is to be saved. A director of 0 means
+// none: units migrated from a director name that matched no user have none, and
+// must stay editable. Callers require director_id where the client sets it.
 func ValidateDepartmentInfo(v *validator.Validator, departmentInfo *DepartmentInfo) {
 	v.Check(departmentInfo.DepartmentName != "", "departmentName", "must be provided")
 	v.Check(len(departmentInfo.DepartmentName) <= 500, "departmentName", "must not be more than 500 bytes long")
-	v.Check(departmentInfo.StaffQuantity != 0, "StaffQuantity", "must be provided")
-	v.Check(departmentInfo.StaffQuantity <= 10, "StaffQuantity", "must not be more than 10")
-	v.Check(departmentInfo.DepartmentDirector != "", "DepartmentDirector", "must be provided")
-	v.Check(len(departmentInfo.DepartmentDirector) <= 500, "DepartmentDirector", "must not be more than 500 bytes long")
-	v.Check(departmentInfo.ModuleID != 0, "ModuleID", "must be provided")
-	v.Check(departmentInfo.ModuleID > 0, "ModuleID", "must be positive number")
+	v.Check(departmentInfo.DirectorID >= 0, "director_id", "must be positive number")
+	v.Check(validator.PermittedValue(departmentInfo.UnitType, UnitTypes...), "unit_type", "invalid unit type")
+	v.Check(departmentInfo.ParentID >= 0, "parent_id", "must be positive number")
+	v.Check(departmentInfo.ParentID == 0 || departmentInfo.ParentID != departmentInfo.ID, "parent_id", "must not reference the unit itself")
 }
 func (m *DepartmentInfoModel) Insert(departmentInfo *DepartmentInfo) error {
-	query := `INSERT INTO department_info(department_name,staff_quantity,department_director,module_id) VALUES($1,$2,$3,$4) RETURNING ID`
-	args := []any{departmentInfo.DepartmentName, departmentInfo.StaffQuantity, departmentInfo.DepartmentDirector, departmentInfo.ModuleID}
-	return m.DB.QueryRow(query, args...).Scan(&departmentInfo.ID)
+	query := `INSERT INTO department_info(department_name,director_id,parent_id,unit_type) VALUES($1,$2,NULLIF($3, 0),$4) RETURNING ID,version`
+	args := []any{departmentInfo.DepartmentName, departmentInfo.DirectorID, departmentInfo.ParentID, departmentInfo.UnitType}
+	departmentInfo.StaffQuantity = 0
+	err := m.DB.QueryRow(query, args...).Scan(&departmentInfo.ID, &departmentInfo.Version)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
 
 }
 func (m *DepartmentInfoModel) Get(id int64) (*DepartmentInfo, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := `SELECT id,department_name,staff_quantity,department_director,module_id FROM department_info WHERE id=$1`
+	query := `SELECT ` + departmentColumns + ` FROM department_info WHERE id=$1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	var departmentInfo DepartmentInfo
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&departmentInfo.ID, &departmentInfo.DepartmentName, &departmentInfo.StaffQuantity, &departmentInfo.DepartmentDirector, &departmentInfo.ModuleID)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(departmentInfo.scanDest()...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -54,3 +114,242 @@ func (m *DepartmentInfoModel) Get(id int64) (*DepartmentInfo, error) {
 	}
 	return &departmentInfo, nil
 }
+func (m *DepartmentInfoModel) Update(departmentInfo *DepartmentInfo) error {
+	query := `UPDATE department_info SET department_name = $1,director_id = NULLIF($2, 0),unit_type = $3,version = version +1 WHERE id=$4 AND version=$5 RETURNING version`
+	args := []any{departmentInfo.DepartmentName, departmentInfo.DirectorID, departmentInfo.UnitType, departmentInfo.ID, departmentInfo.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&departmentInfo.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m *DepartmentInfoModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM department_info WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrUnitHasChildren
+		default:
+			return err
+		}
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+func (m *DepartmentInfoModel) GetAll(DepartmentName string, DirectorName string, within int64, filters Filters) ([]*DepartmentInfo, Metadata, error) {
+	where, args := filters.where(departmentInfoSearch, DepartmentName, DirectorName, within)
+	query, args := filters.pageQuery(`SELECT `+filters.countColumn()+`, `+departmentColumns+`
+	FROM department_info
+	WHERE `+where, args...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, filters.pageQueryError(err)
+	}
+	defer rows.Close()
+	departmentInfos := []*DepartmentInfo{}
+	totalRecords := 0
+	var sortValue any
+	for rows.Next() {
+		var departmentInfo DepartmentInfo
+
+		err := rows.Scan(append([]any{&sortValue, &totalRecords}, departmentInfo.scanDest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		departmentInfos = append(departmentInfos, &departmentInfo)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, filters.pageQueryError(err)
+	}
+	var lastID int64
+	if len(departmentInfos) > 0 {
+		lastID = departmentInfos[len(departmentInfos)-1].ID
+	}
+	metadata := filters.pageMetadata(totalRecords, len(departmentInfos), sortValue, lastID)
+
+	return departmentInfos, metadata, nil
+}
+
+// Export streams every unit matching the same search as GetAll to fn, in sort order,
+// without loading them all into memory. It stops at the first error fn returns.
+func (m *DepartmentInfoModel) Export(DepartmentName string, DirectorName string, within int64, filters Filters, fn func(*DepartmentInfo) error) error {
+	where, args := filters.where(departmentInfoSearch, DepartmentName, DirectorName, within)
+	query := fmt.Sprintf(`SELECT `+departmentColumns+`
+	FROM department_info
+	WHERE `+where+`
+	ORDER BY %s %s,id ASC`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var departmentInfo DepartmentInfo
+		if err := rows.Scan(departmentInfo.scanDest()...); err != nil {
+			return err
+		}
+		if err := fn(&departmentInfo); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DepartmentInfoFilterFields are the fields the unit list and export can be filtered
+// on. A unit without a director or parent compares as 0, as it is shown.
+var DepartmentInfoFilterFields = filter.Fields{
+	"id":              {Column: "department_info.id", Type: filter.Int},
+	"department_name": {Column: "department_info.department_name", Type: filter.String},
+	"staff_quantity":  {Column: staffQuantity, Type: filter.Int},
+	"director_id":     {Column: "COALESCE(department_info.director_id, 0)", Type: filter.Int},
+	"parent_id":       {Column: "COALESCE(department_info.parent_id, 0)", Type: filter.Int},
+	"unit_type":       {Column: "department_info.unit_type", Type: filter.String},
+	"version":         {Column: "department_info.version", Type: filter.Int32},
+}
+
+// departmentInfoSearch is the WHERE clause behind GetAll and Export. It takes the
+// department name, director name and unit id as $1, $2 and $3.
+var departmentInfoSearch = `(to_tsvector('simple', department_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	AND (director_id IN (
+		SELECT user_info.id FROM user_info
+		WHERE to_tsvector('simple', user_info.fname || ' ' || user_info.sname) @@ plainto_tsquery('simple', $2)
+	) OR $2 = '')
+	AND (id IN (` + WithinUnit("$3") + `) OR $3 = 0)`
+
+// GetSubtree returns the unit with the given id followed by every unit nested below
+// it, ordered by depth.
+func (m *DepartmentInfoModel) GetSubtree(id int64) ([]*DepartmentNode, error) {
+	query := `WITH RECURSIVE subtree(id, depth) AS (
+		SELECT id, 0 FROM department_info WHERE id = $1
+		UNION ALL
+		SELECT department_info.id, subtree.depth + 1 FROM department_info
+		INNER JOIN subtree ON department_info.parent_id = subtree.id
+		WHERE subtree.depth < $2
+	)
+	SELECT ` + departmentColumns + `, subtree.depth
+	FROM department_info INNER JOIN subtree ON subtree.id = department_info.id
+	ORDER BY subtree.depth, department_info.id`
+	return m.queryNodes(query, id, maxHierarchyDepth)
+}
+
+// GetAncestors returns the path from the top level unit down to the unit with the
+// given id. Depth counts the steps up from the requested unit.
+func (m *DepartmentInfoModel) GetAncestors(id int64) ([]*DepartmentNode, error) {
+	query := `WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+		SELECT id, parent_id, 0 FROM department_info WHERE id = $1
+		UNION ALL
+		SELECT department_info.id, department_info.parent_id, ancestors.depth + 1 FROM department_info
+		INNER JOIN ancestors ON department_info.id = ancestors.parent_id
+		WHERE ancestors.depth < $2
+	)
+	SELECT ` + departmentColumns + `, ancestors.depth
+	FROM department_info INNER JOIN ancestors ON ancestors.id = department_info.id
+	ORDER BY ancestors.depth DESC`
+	return m.queryNodes(query, id, maxHierarchyDepth)
+}
+
+func (m *DepartmentInfoModel) queryNodes(query string, args ...any) ([]*DepartmentNode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	nodes := []*DepartmentNode{}
+	for rows.Next() {
+		node := DepartmentNode{DepartmentInfo: &DepartmentInfo{}}
+		err := rows.Scan(append(node.scanDest(), &node.Depth)...)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &node)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return nodes, nil
+}
+
+// maxHierarchyDepth bounds the recursive queries so a loop that slipped past Move
+// cannot make them run forever.
+const maxHierarchyDepth = 64
+
+// Move attaches the unit to a new parent, or makes it a top level unit when parentID
+// is zero. Moves are serialised with an advisory lock so two concurrent moves cannot
+// together form a loop that neither would form alone.
+func (m *DepartmentInfoModel) Move(departmentInfo *DepartmentInfo, parentID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('department_info_hierarchy'))`)
+	if err != nil {
+		return err
+	}
+
+	if parentID != 0 {
+		var exists, loop bool
+		query := `SELECT EXISTS(SELECT 1 FROM department_info WHERE id = $2),
+		$2 IN (` + WithinUnit("$1") + `)`
+		err = tx.QueryRowContext(ctx, query, departmentInfo.ID, parentID).Scan(&exists, &loop)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRecordNotFound
+		}
+		if loop {
+			return ErrHierarchyLoop
+		}
+	}
+
+	query := `UPDATE department_info SET parent_id = NULLIF($1, 0),version = version +1 WHERE id=$2 AND version=$3 RETURNING version`
+	err = tx.QueryRowContext(ctx, query, parentID, departmentInfo.ID, departmentInfo.Version).Scan(&departmentInfo.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	departmentInfo.ParentID = parentID
+	return tx.Commit()
+}
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"time"
+)
+
+// DepartmentModuleModel manages the department_modules join table which links
+// departments to the modules they own.
+type DepartmentModuleModel struct {
+	DB *sql.DB
+}
+
+func ValidateModuleIDs(v *validator.Validator, moduleIDs []int64) {
+	v.Check(len(moduleIDs) != 0, "module_ids", "must contain at least one module id")
+	v.Check(len(moduleIDs) <= 100, "module_ids", "must not contain more than 100 module ids")
+	v.Check(validator.Unique(moduleIDs), "module_ids", "must not contain duplicate values")
+	for _, id := range moduleIDs {
+		v.Check(id > 0, "module_ids", "must only contain positive numbers")
+	}
+}
+
+// isForeignKeyViolation reports whether err was raised because a referenced row does
+// not exist.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func (m DepartmentModuleModel) AddForDepartment(departmentID int64, moduleIDs ...int64) error {
+	query := `
+INSERT INTO department_modules (department_id, module_id)
+SELECT $1, unnest($2::bigint[])
+ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, departmentID, pq.Array(moduleIDs))
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m DepartmentModuleModel) RemoveForDepartment(departmentID, moduleID int64) error {
+	query := `DELETE FROM department_modules WHERE department_id = $1 AND module_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, departmentID, moduleID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+func (m DepartmentModuleModel) GetModulesForDepartment(departmentID int64) ([]*ModuleInfo, error) {
+	query := `
+SELECT module_info.id, module_info.created_at, module_info.updated_at, module_info.module_name, module_info.module_duration, module_info.exam_type, module_info.capacity, module_info.version
+FROM module_info
+INNER JOIN department_modules ON department_modules.module_id = module_info.id
+WHERE department_modules.department_id = $1 AND module_info.deleted_at IS NULL
+ORDER BY module_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanModuleInfos(rows)
+}
+func (m DepartmentModuleModel) GetDepartmentsForModule(moduleID int64) ([]*DepartmentInfo, error) {
+	query := `
+SELECT ` + departmentColumns + `
+FROM department_info
+INNER JOIN department_modules ON department_modules.department_id = department_info.id
+WHERE department_modules.module_id = $1
+ORDER BY department_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	departmentInfos := []*DepartmentInfo{}
+	for rows.Next() {
+		var departmentInfo DepartmentInfo
+		err := rows.Scan(departmentInfo.scanDest()...)
+		if err != nil {
+			return nil, err
+		}
+		departmentInfos = append(departmentInfos, &departmentInfo)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return departmentInfos, nil
+}
+
+// GetModulesForDepartments loads the modules of several departments in one query,
+// keyed by department id. Departments without modules are absent from the map.
+func (m DepartmentModuleModel) GetModulesForDepartments(departmentIDs []int64) (map[int64][]*ModuleInfo, error) {
+	query := `
+SELECT department_modules.department_id, module_info.id, module_info.created_at, module_info.updated_at, module_info.module_name, module_info.module_duration, module_info.exam_type, module_info.capacity, module_info.version
+FROM module_info
+INNER JOIN department_modules ON department_modules.module_id = module_info.id
+WHERE department_modules.department_id = ANY($1) AND module_info.deleted_at IS NULL
+ORDER BY module_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(departmentIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	moduleInfos := make(map[int64][]*ModuleInfo)
+	for rows.Next() {
+		var departmentID int64
+		var moduleInfo ModuleInfo
+		err := rows.Scan(&departmentID, &moduleInfo.ID, &moduleInfo.CreatedAt, &moduleInfo.UpdatedAt, &moduleInfo.ModuleName, &moduleInfo.ModuleDuration, &moduleInfo.ExamType, &moduleInfo.Capacity, &moduleInfo.Version)
+		if err != nil {
+			return nil, err
+		}
+		moduleInfos[departmentID] = append(moduleInfos[departmentID], &moduleInfo)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return moduleInfos, nil
+}
+
+// GetDepartmentsForModules loads the departments owning several modules in one
+// query, keyed by module id.
+func (m DepartmentModuleModel) GetDepartmentsForModules(moduleIDs []int64) (map[int64][]*DepartmentInfo, error) {
+	query := `
+SELECT department_modules.module_id, ` + departmentColumns + `
+FROM department_info
+INNER JOIN department_modules ON department_modules.department_id = department_info.id
+WHERE department_modules.module_id = ANY($1)
+ORDER BY department_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(moduleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	departmentInfos := make(map[int64][]*DepartmentInfo)
+	for rows.Next() {
+		var moduleID int64
+		var departmentInfo DepartmentInfo
+		err := rows.Scan(append([]any{&moduleID}, departmentInfo.scanDest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		departmentInfos[moduleID] = append(departmentInfos[moduleID], &departmentInfo)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return departmentInfos, nil
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"time"
+)
+
+var (
+	ErrDuplicateStaff = errors.New("duplicate staff member")
+)
+
+// StaffRoles lists the roles a user can hold within a department.
+var StaffRoles = []string{"head", "deputy", "professor", "lecturer", "assistant", "administrator"}
+
+// StaffMember is a user's membership of a department. The API leaves Email out for
+// callers without the users:admin permission.
+type StaffMember struct {
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Surname   string    `json:"surname"`
+	Email     string    `json:"email,omitempty"`
+	StaffRole string    `json:"staff_role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+type DepartmentStaffModel struct {
+	DB *sql.DB
+}
+
+func ValidateStaffMember(v *validator.Validator, member *StaffMember) {
+	v.Check(member.UserID > 0, "user_id", "must be provided")
+	v.Check(member.StaffRole != "", "staff_role", "must be provided")
+	v.Check(validator.PermittedValue(member.StaffRole, StaffRoles...), "staff_role", "invalid staff role")
+}
+
+func (m DepartmentStaffModel) Add(departmentID int64, member *StaffMember) error {
+	query := `
+INSERT INTO department_staff (department_id, user_id, staff_role)
+VALUES ($1, $2, $3)
+RETURNING created_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, departmentID, member.UserID, member.StaffRole).Scan(&member.JoinedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "department_staff_pkey":
+			return ErrDuplicateStaff
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m DepartmentStaffModel) Remove(departmentID, userID int64) error {
+	query := `DELETE FROM department_staff WHERE department_id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, departmentID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+func (m DepartmentStaffModel) GetAll(departmentID int64, staffRole string, filters Filters) ([]*StaffMember, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), user_info.id, user_info.fname, user_info.sname, user_info.email, department_staff.staff_role, department_staff.created_at
+	FROM department_staff
+	INNER JOIN user_info ON user_info.id = department_staff.user_id
+	WHERE department_staff.department_id = $1 AND user_info.deleted_at IS NULL
+	AND (department_staff.staff_role = $2 OR $2 = '')
+	ORDER BY %s %s,user_info.id ASC
+	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, departmentID, staffRole, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	members := []*StaffMember{}
+	totalRecords := 0
+	for rows.Next() {
+		var member StaffMember
+
+		err := rows.Scan(&totalRecords, &member.UserID, &member.Name, &member.Surname, &member.Email, &member.StaffRole, &member.JoinedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		members = append(members, &member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return members, metadata, nil
+}
+
+// GetForDepartments loads the staff of several departments in one query, keyed by
+// department id and ordered by surname.
+func (m DepartmentStaffModel) GetForDepartments(departmentIDs []int64) (map[int64][]*StaffMember, error) {
+	query := `SELECT department_staff.department_id, user_info.id, user_info.fname, user_info.sname, user_info.email, department_staff.staff_role, department_staff.created_at
+	FROM department_staff
+	INNER JOIN user_info ON user_info.id = department_staff.user_id
+	WHERE department_staff.department_id = ANY($1) AND user_info.deleted_at IS NULL
+	ORDER BY user_info.sname, user_info.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(departmentIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := make(map[int64][]*StaffMember)
+	for rows.Next() {
+		var departmentID int64
+		var member StaffMember
+
+		err := rows.Scan(&departmentID, &member.UserID, &member.Name, &member.Surname, &member.Email, &member.StaffRole, &member.JoinedAt)
+		if err != nil {
+			return nil, err
+		}
+		members[departmentID] = append(members[departmentID], &member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
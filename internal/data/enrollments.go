@@ -0,0 +1,260 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	EnrollmentEnrolled   = "enrolled"
+	EnrollmentWaitlisted = "waitlisted"
+)
+
+var (
+	ErrAlreadyEnrolled = errors.New("already enrolled")
+)
+
+// Enrollment links a student to a module. Students beyond the module's capacity are
+// kept on a waitlist, ordered by the time they signed up.
+type Enrollment struct {
+	ID               int64     `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UserID           int64     `json:"user_id"`
+	ModuleID         int64     `json:"module_id"`
+	Status           string    `json:"status"`
+	WaitlistPosition int       `json:"waitlist_position,omitempty"`
+}
+
+type EnrollmentModel struct {
+	DB *sql.DB
+}
+
+// lockModule takes a row lock on the module for the rest of the transaction and
+// returns its capacity. Every change to a module's enrollments goes through this lock,
+// so concurrent sign-ups are serialised and capacity can never be exceeded.
+func lockModule(ctx context.Context, tx *sql.Tx, moduleID int64) (int32, error) {
+	var capacity int32
+	err := tx.QueryRowContext(ctx, `SELECT capacity FROM module_info WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, moduleID).Scan(&capacity)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return capacity, nil
+}
+
+// Enroll signs the user up for the module, placing them on the waitlist when the
+// module is already full.
+func (m EnrollmentModel) Enroll(userID, moduleID int64) (*Enrollment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockModule(ctx, tx, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	var enrolled, waitlisted int32
+	query := `
+SELECT count(*) FILTER (WHERE status = 'enrolled'), count(*) FILTER (WHERE status = 'waitlisted')
+FROM enrollments
+WHERE module_id = $1`
+	err = tx.QueryRowContext(ctx, query, moduleID).Scan(&enrolled, &waitlisted)
+	if err != nil {
+		return nil, err
+	}
+	enrollment := &Enrollment{
+		UserID:   userID,
+		ModuleID: moduleID,
+		Status:   EnrollmentEnrolled,
+	}
+	if enrolled >= capacity {
+		enrollment.Status = EnrollmentWaitlisted
+		enrollment.WaitlistPosition = int(waitlisted) + 1
+	}
+	query = `INSERT INTO enrollments (user_id, module_id, status) VALUES ($1, $2, $3) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query, userID, moduleID, enrollment.Status).Scan(&enrollment.ID, &enrollment.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "enrollments_user_module_key":
+			return nil, ErrAlreadyEnrolled
+		case isForeignKeyViolation(err):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// Drop removes the user from the module, whether enrolled or waitlisted, and returns
+// any waitlisted enrollments that were promoted into the freed place.
+func (m EnrollmentModel) Drop(userID, moduleID int64) ([]*Enrollment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockModule(ctx, tx, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM enrollments WHERE user_id = $1 AND module_id = $2`, userID, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	promoted, err := promoteWaitlisted(ctx, tx, moduleID, capacity)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return promoted, nil
+}
+
+// Promote fills any free places in the module from its waitlist. It is used after the
+// capacity of a module has been changed.
+func (m EnrollmentModel) Promote(moduleID int64) ([]*Enrollment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockModule(ctx, tx, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	promoted, err := promoteWaitlisted(ctx, tx, moduleID, capacity)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return promoted, nil
+}
+
+// promoteWaitlisted must be called with the module row already locked.
+func promoteWaitlisted(ctx context.Context, tx *sql.Tx, moduleID int64, capacity int32) ([]*Enrollment, error) {
+	query := `
+UPDATE enrollments SET status = 'enrolled'
+WHERE id IN (
+	SELECT id FROM enrollments
+	WHERE module_id = $1 AND status = 'waitlisted'
+	ORDER BY created_at, id
+	LIMIT GREATEST($2 - (SELECT count(*) FROM enrollments WHERE module_id = $1 AND status = 'enrolled'), 0)
+)
+RETURNING id, created_at, user_id, module_id, status`
+	rows, err := tx.QueryContext(ctx, query, moduleID, capacity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEnrollments(rows)
+}
+
+// GetForModule returns the enrolled students followed by the waitlist in order.
+func (m EnrollmentModel) GetForModule(moduleID int64) ([]*Enrollment, error) {
+	query := `
+SELECT id, created_at, user_id, module_id, status
+FROM enrollments
+WHERE module_id = $1
+ORDER BY status = 'waitlisted', created_at, id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	enrollments, err := scanEnrollments(rows)
+	if err != nil {
+		return nil, err
+	}
+	position := 0
+	for _, enrollment := range enrollments {
+		if enrollment.Status == EnrollmentWaitlisted {
+			position++
+			enrollment.WaitlistPosition = position
+		}
+	}
+	return enrollments, nil
+}
+func (m EnrollmentModel) GetForUser(userID int64) ([]*Enrollment, error) {
+	query := `
+SELECT e.id, e.created_at, e.user_id, e.module_id, e.status,
+	CASE WHEN e.status = 'waitlisted' THEN (
+		SELECT count(*) FROM enrollments w
+		WHERE w.module_id = e.module_id AND w.status = 'waitlisted'
+		AND (w.created_at, w.id) <= (e.created_at, e.id)
+	) ELSE 0 END
+FROM enrollments e
+WHERE e.user_id = $1
+ORDER BY e.created_at, e.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	enrollments := []*Enrollment{}
+	for rows.Next() {
+		var enrollment Enrollment
+		err := rows.Scan(&enrollment.ID, &enrollment.CreatedAt, &enrollment.UserID, &enrollment.ModuleID, &enrollment.Status, &enrollment.WaitlistPosition)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, &enrollment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
+func scanEnrollments(rows *sql.Rows) ([]*Enrollment, error) {
+	enrollments := []*Enrollment{}
+	for rows.Next() {
+		var enrollment Enrollment
+		err := rows.Scan(&enrollment.ID, &enrollment.CreatedAt, &enrollment.UserID, &enrollment.ModuleID, &enrollment.Status)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, &enrollment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
@@ -0,0 +1,228 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"time"
+)
+
+var (
+	ErrDuplicateResult = errors.New("duplicate result")
+)
+
+// ExamResult is the score a student achieved in a module. Letter and GPAPoints are
+// not stored; they are worked out from the grading scale when the result is read so
+// that a change to the scale applies to every result.
+type ExamResult struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	UserID     int64     `json:"user_id"`
+	ModuleID   int64     `json:"module_id"`
+	Score      float64   `json:"score"`
+	Letter     string    `json:"letter"`
+	GPAPoints  float64   `json:"gpa_points"`
+	RecordedBy int64     `json:"recorded_by"`
+	Version    int32     `json:"version"`
+	// ExamType is the exam type of the module, which decides the grading scale. It
+	// is read along with the result so results of modules in the trash still grade.
+	ExamType string `json:"-"`
+}
+
+// TranscriptEntry is a single graded module on a student's transcript.
+type TranscriptEntry struct {
+	ModuleID       int64   `json:"module_id"`
+	ModuleName     string  `json:"module_name"`
+	ExamType       string  `json:"exam_type"`
+	ModuleDuration int32   `json:"module_duration"`
+	Score          float64 `json:"score"`
+	Letter         string  `json:"letter"`
+	GPAPoints      float64 `json:"gpa_points"`
+}
+
+// Transcript lists a student's results with a GPA weighted by module_duration.
+type Transcript struct {
+	UserID  int64              `json:"user_id"`
+	Entries []*TranscriptEntry `json:"entries"`
+	GPA     float64            `json:"gpa"`
+}
+
+// GradeDistribution summarises the results of a module.
+type GradeDistribution struct {
+	ModuleID     int64          `json:"module_id"`
+	Count        int            `json:"count"`
+	AverageScore float64        `json:"average_score"`
+	Letters      map[string]int `json:"letters"`
+}
+
+type ExamResultModel struct {
+	DB *sql.DB
+}
+
+func ValidateExamResult(v *validator.Validator, result *ExamResult) {
+	v.Check(result.UserID > 0, "user_id", "must be provided")
+	v.Check(result.ModuleID > 0, "module_id", "must be provided")
+	v.Check(result.Score >= 0, "score", "must not be negative")
+	v.Check(result.Score <= 100, "score", "must not be more than 100")
+}
+
+func (m ExamResultModel) Insert(result *ExamResult) error {
+	// Nothing is inserted for a module in the trash, which reads as not found.
+	query := `
+INSERT INTO exam_results(user_id,module_id,score,recorded_by)
+SELECT $1,module_info.id,$3,$4 FROM module_info WHERE module_info.id=$2 AND module_info.deleted_at IS NULL
+RETURNING id,created_at,updated_at,version,(SELECT exam_type FROM module_info WHERE module_info.id = exam_results.module_id)`
+	args := []any{result.UserID, result.ModuleID, result.Score, result.RecordedBy}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&result.ID, &result.CreatedAt, &result.UpdatedAt, &result.Version, &result.ExamType)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqErr) && pqErr.Constraint == "exam_results_user_module_key":
+			return ErrDuplicateResult
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m ExamResultModel) Get(id int64) (*ExamResult, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+SELECT exam_results.id,exam_results.created_at,exam_results.updated_at,exam_results.user_id,exam_results.module_id,exam_results.score,COALESCE(exam_results.recorded_by, 0),exam_results.version,module_info.exam_type
+FROM exam_results
+INNER JOIN module_info ON module_info.id = exam_results.module_id
+WHERE exam_results.id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var result ExamResult
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&result.ID, &result.CreatedAt, &result.UpdatedAt, &result.UserID, &result.ModuleID, &result.Score, &result.RecordedBy, &result.Version, &result.ExamType)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &result, nil
+}
+func (m ExamResultModel) Update(result *ExamResult) error {
+	query := `UPDATE exam_results SET score = $1,recorded_by = $2,updated_at = NOW(),version = version +1 WHERE id=$3 AND version=$4
+RETURNING updated_at,version,(SELECT exam_type FROM module_info WHERE module_info.id = exam_results.module_id)`
+	args := []any{result.Score, result.RecordedBy, result.ID, result.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&result.UpdatedAt, &result.Version, &result.ExamType)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m ExamResultModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM exam_results WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetTranscript returns every result for the student graded with the given scales.
+func (m ExamResultModel) GetTranscript(userID int64, scales GradingScales) (*Transcript, error) {
+	query := `
+SELECT module_info.id, module_info.module_name, module_info.exam_type, module_info.module_duration, exam_results.score
+FROM exam_results
+INNER JOIN module_info ON module_info.id = exam_results.module_id
+WHERE exam_results.user_id = $1
+ORDER BY exam_results.created_at, module_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transcript := &Transcript{UserID: userID, Entries: []*TranscriptEntry{}}
+	var weightedPoints, totalWeight float64
+	for rows.Next() {
+		var entry TranscriptEntry
+		err := rows.Scan(&entry.ModuleID, &entry.ModuleName, &entry.ExamType, &entry.ModuleDuration, &entry.Score)
+		if err != nil {
+			return nil, err
+		}
+		entry.Letter, entry.GPAPoints = scales.For(entry.ExamType).Grade(entry.Score)
+		weightedPoints += entry.GPAPoints * float64(entry.ModuleDuration)
+		totalWeight += float64(entry.ModuleDuration)
+		transcript.Entries = append(transcript.Entries, &entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if totalWeight > 0 {
+		transcript.GPA = weightedPoints / totalWeight
+	}
+	return transcript, nil
+}
+
+// GetDistribution counts how many results of the module fall into each letter grade.
+func (m ExamResultModel) GetDistribution(moduleID int64, scale GradingScale) (*GradeDistribution, error) {
+	query := `SELECT score FROM exam_results WHERE module_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	distribution := &GradeDistribution{ModuleID: moduleID, Letters: make(map[string]int)}
+	for _, band := range scale {
+		distribution.Letters[band.Letter] = 0
+	}
+	var total float64
+	for rows.Next() {
+		var score float64
+		err := rows.Scan(&score)
+		if err != nil {
+			return nil, err
+		}
+		letter, _ := scale.Grade(score)
+		distribution.Letters[letter]++
+		distribution.Count++
+		total += score
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if distribution.Count > 0 {
+		distribution.AverageScore = total / float64(distribution.Count)
+	}
+	return distribution, nil
+}
@@ -0,0 +1,213 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"time"
+)
+
+var (
+	ErrRoomDoubleBooked = errors.New("room double booked")
+)
+
+// ExamSession is a scheduled sitting of a module's exam in a room. Postgres enforces
+// through an exclusion constraint that no two sessions in the same room overlap.
+type ExamSession struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ModuleID  int64     `json:"module_id"`
+	Room      string    `json:"room"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Version   int32     `json:"version"`
+}
+
+// StudentConflict records a student enrolled in the session's module who also sits
+// another exam at an overlapping time.
+type StudentConflict struct {
+	UserID            int64 `json:"user_id"`
+	ConflictingExam   int64 `json:"conflicting_exam_id"`
+	ConflictingModule int64 `json:"conflicting_module_id"`
+}
+
+type ExamSessionModel struct {
+	DB *sql.DB
+}
+
+func ValidateExamSession(v *validator.Validator, session *ExamSession) {
+	v.Check(session.ModuleID > 0, "module_id", "must be provided")
+	v.Check(session.Room != "", "room", "must be provided")
+	v.Check(len(session.Room) <= 255, "room", "must not be more than 255 bytes long")
+	v.Check(!session.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!session.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(session.EndsAt.After(session.StartsAt), "ends_at", "must be after starts_at")
+	v.Check(session.EndsAt.Sub(session.StartsAt) <= 12*time.Hour, "ends_at", "session must not be longer than 12 hours")
+}
+
+func isRoomOverlap(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Constraint == "exam_sessions_room_overlap"
+}
+
+func (m ExamSessionModel) Insert(session *ExamSession) error {
+	query := `INSERT INTO exam_sessions(module_id,room,starts_at,ends_at) VALUES($1,$2,$3,$4) RETURNING id,created_at,version`
+	args := []any{session.ModuleID, session.Room, session.StartsAt, session.EndsAt}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&session.ID, &session.CreatedAt, &session.Version)
+	if err != nil {
+		switch {
+		case isRoomOverlap(err):
+			return ErrRoomDoubleBooked
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m ExamSessionModel) Get(id int64) (*ExamSession, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id,created_at,module_id,room,starts_at,ends_at,version FROM exam_sessions WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var session ExamSession
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&session.ID, &session.CreatedAt, &session.ModuleID, &session.Room, &session.StartsAt, &session.EndsAt, &session.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &session, nil
+}
+func (m ExamSessionModel) Update(session *ExamSession) error {
+	query := `UPDATE exam_sessions SET module_id = $1,room = $2,starts_at = $3,ends_at = $4,version = version +1 WHERE id=$5 AND version=$6 RETURNING version`
+	args := []any{session.ModuleID, session.Room, session.StartsAt, session.EndsAt, session.ID, session.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&session.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case isRoomOverlap(err):
+			return ErrRoomDoubleBooked
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m ExamSessionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM exam_sessions WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll lists sessions which overlap the [from, to) window. A zero from or to leaves
+// that side of the window open.
+func (m ExamSessionModel) GetAll(from, to time.Time, moduleID int64, room string, filters Filters) ([]*ExamSession, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id,created_at,module_id,room,starts_at,ends_at,version
+	FROM exam_sessions
+	WHERE (ends_at > $1 OR $1 IS NULL)
+	AND (starts_at < $2 OR $2 IS NULL)
+	AND (module_id = $3 OR $3 = 0)
+	AND (room = $4 OR $4 = '')
+	ORDER BY %s %s,id ASC
+	LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{nullTime(from), nullTime(to), moduleID, room, filters.limit(), filters.offset()}
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	sessions := []*ExamSession{}
+	totalRecords := 0
+	for rows.Next() {
+		var session ExamSession
+
+		err := rows.Scan(&totalRecords, &session.ID, &session.CreatedAt, &session.ModuleID, &session.Room, &session.StartsAt, &session.EndsAt, &session.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		sessions = append(sessions, &session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return sessions, metadata, nil
+}
+
+// StudentConflicts finds students enrolled in the session's module who are also
+// enrolled in a module with another exam overlapping the session.
+func (m ExamSessionModel) StudentConflicts(session *ExamSession) ([]StudentConflict, error) {
+	query := `
+SELECT DISTINCT own.user_id, other_session.id, other_session.module_id
+FROM enrollments own
+INNER JOIN enrollments other ON other.user_id = own.user_id AND other.module_id <> own.module_id
+INNER JOIN exam_sessions other_session ON other_session.module_id = other.module_id
+WHERE own.module_id = $1
+AND own.status = 'enrolled'
+AND other.status = 'enrolled'
+AND other_session.id <> $2
+AND other_session.starts_at < $4
+AND other_session.ends_at > $3
+ORDER BY own.user_id, other_session.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, session.ModuleID, session.ID, session.StartsAt, session.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	conflicts := []StudentConflict{}
+	for rows.Next() {
+		var conflict StudentConflict
+		err := rows.Scan(&conflict.UserID, &conflict.ConflictingExam, &conflict.ConflictingModule)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
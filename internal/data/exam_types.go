@@ -0,0 +1,161 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrDuplicateExamType = errors.New("duplicate exam type")
+	ErrExamTypeInUse     = errors.New("exam type in use")
+)
+
+// ExamTypeCodeRX matches the codes modules use to reference an exam type.
+var ExamTypeCodeRX = regexp.MustCompile("^[a-z0-9_]+$")
+
+// ExamType is an entry in the exam type catalogue. Weighting is the percentage of the
+// final mark that an exam of this type contributes.
+type ExamType struct {
+	Code        string    `json:"code"`
+	CreatedAt   time.Time `json:"created_at"`
+	DisplayName string    `json:"display_name"`
+	Weighting   float64   `json:"weighting"`
+	Version     int32     `json:"version"`
+}
+
+type ExamTypeModel struct {
+	DB *sql.DB
+}
+
+func ValidateExamTypeCode(v *validator.Validator, key string, code string) {
+	v.Check(code != "", key, "must be provided")
+	v.Check(len(code) <= 32, key, "must not be more than 32 bytes long")
+	v.Check(validator.Matches(code, ExamTypeCodeRX), key, "must contain only lowercase letters, digits and underscores")
+}
+func ValidateExamType(v *validator.Validator, examType *ExamType) {
+	ValidateExamTypeCode(v, "code", examType.Code)
+	v.Check(examType.DisplayName != "", "display_name", "must be provided")
+	v.Check(len(examType.DisplayName) <= 255, "display_name", "must not be more than 255 bytes long")
+	v.Check(examType.Weighting >= 0, "weighting", "must not be negative")
+	v.Check(examType.Weighting <= 100, "weighting", "must not be more than 100")
+}
+
+func (m ExamTypeModel) Insert(examType *ExamType) error {
+	query := `INSERT INTO exam_types(code,display_name,weighting) VALUES($1,$2,$3) RETURNING created_at,version`
+	args := []any{examType.Code, examType.DisplayName, examType.Weighting}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&examType.CreatedAt, &examType.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "exam_types_pkey":
+			return ErrDuplicateExamType
+		default:
+			return err
+		}
+	}
+	return nil
+}
+func (m ExamTypeModel) Get(code string) (*ExamType, error) {
+	if code == "" {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT code,created_at,display_name,weighting,version FROM exam_types WHERE code=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var examType ExamType
+	err := m.DB.QueryRowContext(ctx, query, code).Scan(&examType.Code, &examType.CreatedAt, &examType.DisplayName, &examType.Weighting, &examType.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &examType, nil
+}
+
+// GetAll returns the whole catalogue ordered by code. It is small enough that it is
+// never paginated.
+func (m ExamTypeModel) GetAll() ([]*ExamType, error) {
+	query := `SELECT code,created_at,display_name,weighting,version FROM exam_types ORDER BY code`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	examTypes := []*ExamType{}
+	for rows.Next() {
+		var examType ExamType
+		err := rows.Scan(&examType.Code, &examType.CreatedAt, &examType.DisplayName, &examType.Weighting, &examType.Version)
+		if err != nil {
+			return nil, err
+		}
+		examTypes = append(examTypes, &examType)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return examTypes, nil
+}
+
+// Update changes the display name and weighting. Codes are permanent because modules
+// and grading scales refer to them.
+func (m ExamTypeModel) Update(examType *ExamType) error {
+	query := `UPDATE exam_types SET display_name=$1,weighting=$2,version=version +1 WHERE code=$3 AND version=$4 RETURNING version`
+	args := []any{examType.DisplayName, examType.Weighting, examType.Code, examType.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&examType.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes an exam type and its grading scale. It fails with ErrExamTypeInUse
+// while any module, including one in the trash, still references the code.
+func (m ExamTypeModel) Delete(code string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `DELETE FROM grading_scales WHERE exam_type = $1`, code)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM exam_types WHERE code = $1`, code)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrExamTypeInUse
+		default:
+			return err
+		}
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return tx.Commit()
+}
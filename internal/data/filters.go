@@ -1,23 +1,41 @@
 package data
 
 import (
+	"golangHW.darkhanomirbay/internal/filter"
 	"golangHW.darkhanomirbay/internal/validator"
 	"math"
 	"strings"
+	"time"
 )
 
+// exportTimeout bounds the queries behind the streaming exports, which read every
+// matching row rather than a single page.
+const exportTimeout = 10 * time.Minute
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+	// Cursor continues a listing after the row it encodes, taken from a previous
+	// page's next_cursor. Page is not used with it.
+	Cursor string
+	// SkipCount leaves the total out of the metadata, which spares the database
+	// counting every matching row. The list handlers set it by default for cursor
+	// requests, so walking a listing does not count it again on every page.
+	SkipCount bool
+	// Filter is a filter expression (see package filter) narrowing the listing
+	// further, over the fields in FilterFields.
+	Filter       string
+	FilterFields filter.Fields
 }
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -37,8 +55,48 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	ValidateSort(v, f)
+	ValidateFilterExpression(v, f)
+	if f.Cursor != "" {
+		v.Check(f.Page == 1, "page", "must not be combined with cursor")
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "is invalid")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "was issued for a different sort")
+	}
+}
+
+// ValidateSort checks only the sort key. Exports use it on its own because they read
+// every matching row and take no page or page_size.
+func ValidateSort(v *validator.Validator, f Filters) {
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
 }
+
+// ValidateFilterExpression checks that the filter expression parses against the
+// resource's fields. Exports call it alongside ValidateSort.
+func ValidateFilterExpression(v *validator.Validator, f Filters) {
+	if f.Filter == "" {
+		return
+	}
+	_, err := filter.Parse(f.Filter, f.FilterFields)
+	if err != nil {
+		v.AddError("filter", err.Error())
+	}
+}
+
+// where returns search, a WHERE clause using args as $1..$n, narrowed by the filter
+// expression, together with the arguments of both.
+func (f Filters) where(search string, args ...any) (string, []any) {
+	if f.Filter == "" {
+		return search, args
+	}
+	expr, err := filter.Parse(f.Filter, f.FilterFields)
+	if err != nil {
+		panic("unsafe filter parameter: " + err.Error())
+	}
+	clause, filterArgs := filter.Compile(expr, len(args)+1)
+	return "(" + search + ") AND " + clause, append(args, filterArgs...)
+}
+
 func (f Filters) sortColumn() string {
 	for _, safeValue := range f.SortSafeList {
 		if f.Sort == safeValue {
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"golangHW.darkhanomirbay/internal/validator"
+	"sort"
+	"time"
+)
+
+// GradeBand maps every score at or above MinScore (and below the next band) to a
+// letter grade and its GPA points.
+type GradeBand struct {
+	Letter    string  `json:"letter"`
+	MinScore  float64 `json:"min_score"`
+	GPAPoints float64 `json:"gpa_points"`
+}
+
+// GradingScale is the set of bands for one exam type, ordered from the highest
+// MinScore down.
+type GradingScale []GradeBand
+
+// GradingScales holds a scale per exam type. The scale stored under the empty exam
+// type is the default.
+type GradingScales map[string]GradingScale
+
+// Grade returns the letter and GPA points for a score. A score below every band gets
+// an empty letter and zero points.
+func (s GradingScale) Grade(score float64) (string, float64) {
+	for _, band := range s {
+		if score >= band.MinScore {
+			return band.Letter, band.GPAPoints
+		}
+	}
+	return "", 0
+}
+
+// For returns the scale for the exam type, falling back to the default scale.
+func (s GradingScales) For(examType string) GradingScale {
+	if scale, ok := s[examType]; ok {
+		return scale
+	}
+	return s[""]
+}
+
+func ValidateGradingScale(v *validator.Validator, scale GradingScale) {
+	v.Check(len(scale) != 0, "bands", "must contain at least one band")
+	v.Check(len(scale) <= 20, "bands", "must not contain more than 20 bands")
+	letters := make([]string, 0, len(scale))
+	scores := make([]float64, 0, len(scale))
+	coversZero := false
+	for _, band := range scale {
+		v.Check(band.Letter != "", "bands", "letter must be provided")
+		v.Check(len(band.Letter) <= 5, "bands", "letter must not be more than 5 bytes long")
+		v.Check(band.MinScore >= 0 && band.MinScore <= 100, "bands", "min_score must be between 0 and 100")
+		v.Check(band.GPAPoints >= 0 && band.GPAPoints <= 4, "bands", "gpa_points must be between 0 and 4")
+		letters = append(letters, band.Letter)
+		scores = append(scores, band.MinScore)
+		if band.MinScore == 0 {
+			coversZero = true
+		}
+	}
+	v.Check(validator.Unique(letters), "bands", "must not contain duplicate letters")
+	v.Check(validator.Unique(scores), "bands", "must not contain duplicate min_score values")
+	v.Check(coversZero, "bands", "must contain a band with min_score 0")
+}
+
+type GradingScaleModel struct {
+	DB *sql.DB
+}
+
+func (m GradingScaleModel) GetAll() (GradingScales, error) {
+	query := `SELECT exam_type, letter, min_score, gpa_points FROM grading_scales ORDER BY exam_type, min_score DESC`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scales := make(GradingScales)
+	for rows.Next() {
+		var examType string
+		var band GradeBand
+		err := rows.Scan(&examType, &band.Letter, &band.MinScore, &band.GPAPoints)
+		if err != nil {
+			return nil, err
+		}
+		scales[examType] = append(scales[examType], band)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return scales, nil
+}
+
+// Replace swaps the whole scale for an exam type in one transaction.
+func (m GradingScaleModel) Replace(examType string, scale GradingScale) error {
+	sorted := make(GradingScale, len(scale))
+	copy(sorted, scale)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinScore > sorted[j].MinScore })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM grading_scales WHERE exam_type = $1`, examType)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO grading_scales (exam_type, letter, min_score, gpa_points) VALUES ($1, $2, $3, $4)`
+	for _, band := range sorted {
+		_, err = tx.ExecContext(ctx, query, examType, band.Letter, band.MinScore, band.GPAPoints)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// ErrImportAborted is returned by the bulk inserts when a row fails and failed rows
+// are not being skipped. Nothing has been written.
+var ErrImportAborted = errors.New("import aborted")
+
+// importTimeout bounds a whole bulk insert rather than a single statement.
+const importTimeout = 2 * time.Minute
+
+// importTx runs insert for rows 0..n-1 in a single transaction, each behind its own
+// savepoint. With skipFailed a row that fails is rolled back to its savepoint and
+// the rest carry on; without it the first failure rolls back everything and
+// ErrImportAborted is returned. rowErrs is indexed like the input and holds nil for
+// rows that were inserted.
+func importTx(db *sql.DB, n int, skipFailed bool, insert func(ctx context.Context, tx *sql.Tx, i int) error) ([]error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rowErrs := make([]error, n)
+	for i := 0; i < n; i++ {
+		_, err = tx.ExecContext(ctx, `SAVEPOINT import_row`)
+		if err != nil {
+			return nil, err
+		}
+		rowErr := insert(ctx, tx, i)
+		if rowErr != nil {
+			rowErrs[i] = rowErr
+			if !skipFailed {
+				return rowErrs, ErrImportAborted
+			}
+			_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT import_row`)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return rowErrs, nil
+}
+
+// InsertMany inserts the modules in one transaction. See importTx for how failed
+// rows are handled.
+func (m *ModuleInfoModel) InsertMany(moduleInfos []*ModuleInfo, skipFailed bool) ([]error, error) {
+	return importTx(m.DB, len(moduleInfos), skipFailed, func(ctx context.Context, tx *sql.Tx, i int) error {
+		return insertModuleInfo(ctx, tx, moduleInfos[i])
+	})
+}
+
+// InsertMany inserts the users in one transaction and grants each of them the given
+// permissions. Every user must already have a hashed password. See importTx for how
+// failed rows are handled.
+func (m *UserInfoModel) InsertMany(users []*UserInfo, permissionCodes []string, skipFailed bool) ([]error, error) {
+	query := `INSERT INTO user_info(fname,sname,email,password_hash,user_role,activated) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id,created_at,updated_at,version`
+	grant := `
+INSERT INTO users_permissions
+SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+	return importTx(m.DB, len(users), skipFailed, func(ctx context.Context, tx *sql.Tx, i int) error {
+		user := users[i]
+		args := []any{user.Name, user.Surname, user.Email, user.Password.hash, user.Role, user.Activated}
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
+		if err != nil {
+			var pqErr *pq.Error
+			switch {
+			case errors.As(err, &pqErr) && pqErr.Constraint == "users_email_key":
+				return ErrDuplicateEmail
+			default:
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, grant, user.ID, pq.Array(permissionCodes))
+		if err != nil {
+			return fmt.Errorf("grant permissions: %w", err)
+		}
+		return nil
+	})
+}
+
+// ExistingEmails returns the lower-cased addresses from emails that already belong to
+// a user, including users in the trash.
+func (m *UserInfoModel) ExistingEmails(emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	query := `SELECT lower(email) FROM user_info WHERE email = ANY($1::citext[])`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var email string
+		err := rows.Scan(&email)
+		if err != nil {
+			return nil, err
+		}
+		existing[strings.ToLower(email)] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
@@ -12,16 +12,44 @@ var (
 
 type Models struct {
 	ModuleInfoModel     ModuleInfoModel
+	ModuleHistory       ModuleHistoryModel
+	Attachments         AttachmentModel
 	DepartmentInfoModel DepartmentInfoModel
+	DepartmentModules   DepartmentModuleModel
+	DepartmentStaff     DepartmentStaffModel
+	Prerequisites       PrerequisiteModel
+	Programs            ProgramModel
+	Enrollments         EnrollmentModel
+	ExamResults         ExamResultModel
+	GradingScales       GradingScaleModel
+	ExamSessions        ExamSessionModel
+	Timetables          TimetableModel
+	ExamTypes           ExamTypeModel
 	UserInfoModel       UserInfoModel
+	Search              SearchModel
+	Stats               StatsModel
 	Permissions         PermissionModel // Add a new Permissions field.
 	Tokens              TokenModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{ModuleInfoModel: ModuleInfoModel{DB: db},
+		ModuleHistory:       ModuleHistoryModel{DB: db},
+		Attachments:         AttachmentModel{DB: db},
 		DepartmentInfoModel: DepartmentInfoModel{DB: db},
+		DepartmentModules:   DepartmentModuleModel{DB: db},
+		DepartmentStaff:     DepartmentStaffModel{DB: db},
+		Prerequisites:       PrerequisiteModel{DB: db},
+		Programs:            ProgramModel{DB: db},
+		Enrollments:         EnrollmentModel{DB: db},
+		ExamResults:         ExamResultModel{DB: db},
+		GradingScales:       GradingScaleModel{DB: db},
+		ExamSessions:        ExamSessionModel{DB: db},
+		Timetables:          TimetableModel{DB: db},
+		ExamTypes:           ExamTypeModel{DB: db},
 		UserInfoModel:       UserInfoModel{DB: db},
+		Search:              SearchModel{DB: db},
+		Stats:               StatsModel{DB: db},
 		Permissions:         PermissionModel{DB: db},
 		Tokens:              TokenModel{DB: db},
 	}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchActions lists the operations a module batch may contain.
+var BatchActions = []string{BatchCreate, BatchUpdate, BatchDelete}
+
+// ModuleBatchOp is one operation of a module batch. For a create ModuleInfo holds
+// the new module; for an update it holds the complete new state, with Version set
+// to the version the client expects to replace; for a delete only ID and,
+// optionally, Version are used.
+type ModuleBatchOp struct {
+	Action     string
+	ModuleInfo *ModuleInfo
+}
+
+// batchTimeout bounds a whole batch rather than a single statement.
+const batchTimeout = 30 * time.Second
+
+// ApplyBatch runs the operations in order in a single transaction. Either every
+// operation is applied, or none is and the index of the one that failed is returned
+// with its error: ErrRecordNotFound, ErrEditConflict or a database error. On
+// success the modules of creates and updates have their new id and version filled
+// in, and the returned index is -1.
+func (m *ModuleInfoModel) ApplyBatch(ops []ModuleBatchOp) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	for i, op := range ops {
+		switch op.Action {
+		case BatchCreate:
+			err = insertModuleInfo(ctx, tx, op.ModuleInfo)
+		case BatchUpdate:
+			err = updateModuleInfo(ctx, tx, op.ModuleInfo)
+		case BatchDelete:
+			err = deleteModuleInfo(ctx, tx, op.ModuleInfo.ID, op.ModuleInfo.Version)
+		default:
+			err = fmt.Errorf("unknown batch action %q", op.Action)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return -1, tx.Commit()
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ModuleRevision is a snapshot of a module as it was at one version.
+type ModuleRevision struct {
+	ModuleID       int64     `json:"module_id"`
+	Version        int32     `json:"version"`
+	ModuleName     string    `json:"module_name"`
+	ModuleDuration int32     `json:"module_duration"`
+	ExamType       string    `json:"exam_type"`
+	Capacity       int32     `json:"capacity"`
+	ChangedBy      int64     `json:"changed_by"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// FieldChange is one field that differs between two revisions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+type ModuleHistoryModel struct {
+	DB *sql.DB
+}
+
+// insertRevision records the module's current state as the snapshot for its version.
+// It runs in the same transaction as the write that produced that version, so the
+// history can never skip or disagree with a version the module actually had.
+func insertRevision(ctx context.Context, tx *sql.Tx, moduleInfo *ModuleInfo) error {
+	query := `INSERT INTO module_info_history (module_id, version, module_name, module_duration, exam_type, capacity, changed_by)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0))`
+	args := []any{moduleInfo.ID, moduleInfo.Version, moduleInfo.ModuleName, moduleInfo.ModuleDuration, moduleInfo.ExamType, moduleInfo.Capacity, moduleInfo.UpdatedBy}
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetAll returns the revisions of a module, newest first unless the filters ask for
+// another order.
+func (m ModuleHistoryModel) GetAll(moduleID int64, filters Filters) ([]*ModuleRevision, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), module_id, version, module_name, module_duration, exam_type, capacity, COALESCE(changed_by, 0), changed_at
+	FROM module_info_history
+	WHERE module_id = $1
+	ORDER BY %s %s
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, moduleID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	revisions := []*ModuleRevision{}
+	totalRecords := 0
+	for rows.Next() {
+		var revision ModuleRevision
+		err := rows.Scan(&totalRecords, &revision.ModuleID, &revision.Version, &revision.ModuleName, &revision.ModuleDuration, &revision.ExamType, &revision.Capacity, &revision.ChangedBy, &revision.ChangedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+func (m ModuleHistoryModel) Get(moduleID int64, version int32) (*ModuleRevision, error) {
+	if moduleID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT module_id, version, module_name, module_duration, exam_type, capacity, COALESCE(changed_by, 0), changed_at
+	FROM module_info_history
+	WHERE module_id = $1 AND version = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var revision ModuleRevision
+	err := m.DB.QueryRowContext(ctx, query, moduleID, version).Scan(&revision.ModuleID, &revision.Version, &revision.ModuleName, &revision.ModuleDuration, &revision.ExamType, &revision.Capacity, &revision.ChangedBy, &revision.ChangedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &revision, nil
+}
+
+// DiffRevisions lists the fields whose values differ between two revisions, in a
+// fixed order.
+func DiffRevisions(from, to *ModuleRevision) []FieldChange {
+	changes := []FieldChange{}
+	if from.ModuleName != to.ModuleName {
+		changes = append(changes, FieldChange{Field: "module_name", From: from.ModuleName, To: to.ModuleName})
+	}
+	if from.ModuleDuration != to.ModuleDuration {
+		changes = append(changes, FieldChange{Field: "module_duration", From: from.ModuleDuration, To: to.ModuleDuration})
+	}
+	if from.ExamType != to.ExamType {
+		changes = append(changes, FieldChange{Field: "exam_type", From: from.ExamType, To: to.ExamType})
+	}
+	if from.Capacity != to.Capacity {
+		changes = append(changes, FieldChange{Field: "capacity", From: from.Capacity, To: to.Capacity})
+	}
+	return changes
+}
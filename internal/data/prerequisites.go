@@ -0,0 +1,215 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"sort"
+	"time"
+)
+
+var (
+	ErrPrerequisiteCycle = errors.New("prerequisite cycle")
+)
+
+// PrerequisiteModel manages the module_prerequisites table. Each row states that
+// module_id requires prerequisite_id to be completed first, so the table as a whole
+// forms a directed acyclic graph of modules.
+type PrerequisiteModel struct {
+	DB *sql.DB
+}
+
+// Add inserts a new prerequisite edge. The check for cycles and the insert run in the
+// same transaction while holding a lock on the table, so two concurrent requests can't
+// each add one half of a cycle.
+func (m PrerequisiteModel) Add(moduleID, prerequisiteID int64) error {
+	if moduleID == prerequisiteID {
+		return ErrPrerequisiteCycle
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE module_prerequisites IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+	// The new edge closes a cycle if moduleID is already reachable from
+	// prerequisiteID by following existing prerequisite edges.
+	query := `
+WITH RECURSIVE reachable(id) AS (
+	SELECT prerequisite_id FROM module_prerequisites WHERE module_id = $1
+	UNION
+	SELECT module_prerequisites.prerequisite_id
+	FROM module_prerequisites
+	INNER JOIN reachable ON module_prerequisites.module_id = reachable.id
+)
+SELECT EXISTS(SELECT 1 FROM reachable WHERE id = $2)`
+	var cycle bool
+	err = tx.QueryRowContext(ctx, query, prerequisiteID, moduleID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrPrerequisiteCycle
+	}
+	query = `
+INSERT INTO module_prerequisites (module_id, prerequisite_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING`
+	_, err = tx.ExecContext(ctx, query, moduleID, prerequisiteID)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return tx.Commit()
+}
+func (m PrerequisiteModel) Remove(moduleID, prerequisiteID int64) error {
+	query := `DELETE FROM module_prerequisites WHERE module_id = $1 AND prerequisite_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, moduleID, prerequisiteID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetForModule returns the direct prerequisites of a module ordered by id.
+func (m PrerequisiteModel) GetForModule(moduleID int64) ([]*ModuleInfo, error) {
+	query := `
+SELECT module_info.id, module_info.created_at, module_info.updated_at, module_info.module_name, module_info.module_duration, module_info.exam_type, module_info.capacity, module_info.version
+FROM module_info
+INNER JOIN module_prerequisites ON module_prerequisites.prerequisite_id = module_info.id
+WHERE module_prerequisites.module_id = $1 AND module_info.deleted_at IS NULL
+ORDER BY module_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanModuleInfos(rows)
+}
+
+// GetTransitiveForModule returns every module that has to be completed before the
+// given one, ordered so that each module appears after all of its own prerequisites.
+func (m PrerequisiteModel) GetTransitiveForModule(moduleID int64) ([]*ModuleInfo, error) {
+	query := `
+WITH RECURSIVE edges(module_id, prerequisite_id) AS (
+	SELECT module_id, prerequisite_id FROM module_prerequisites WHERE module_id = $1
+	UNION
+	SELECT module_prerequisites.module_id, module_prerequisites.prerequisite_id
+	FROM module_prerequisites
+	INNER JOIN edges ON module_prerequisites.module_id = edges.prerequisite_id
+)
+SELECT module_id, prerequisite_id FROM edges`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	requires := make(map[int64][]int64)
+	for rows.Next() {
+		var from, to int64
+		err := rows.Scan(&from, &to)
+		if err != nil {
+			return nil, err
+		}
+		requires[from] = append(requires[from], to)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	order := topologicalOrder(moduleID, requires)
+	if len(order) == 0 {
+		return []*ModuleInfo{}, nil
+	}
+
+	query = `
+SELECT id, created_at, updated_at, module_name, module_duration, exam_type, capacity, version
+FROM module_info
+WHERE id = ANY($1) AND deleted_at IS NULL`
+	moduleRows, err := m.DB.QueryContext(ctx, query, pq.Array(order))
+	if err != nil {
+		return nil, err
+	}
+	defer moduleRows.Close()
+	moduleInfos, err := scanModuleInfos(moduleRows)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*ModuleInfo, len(moduleInfos))
+	for _, moduleInfo := range moduleInfos {
+		byID[moduleInfo.ID] = moduleInfo
+	}
+	sorted := make([]*ModuleInfo, 0, len(order))
+	for _, id := range order {
+		if moduleInfo, ok := byID[id]; ok {
+			sorted = append(sorted, moduleInfo)
+		}
+	}
+	return sorted, nil
+}
+
+// topologicalOrder runs Kahn's algorithm over the prerequisite graph reachable from
+// root and returns the ids of every prerequisite (root itself excluded) so that each
+// id comes after everything it requires. Ties are broken by the smallest id to keep
+// the output stable between calls.
+func topologicalOrder(root int64, requires map[int64][]int64) []int64 {
+	pending := make(map[int64]int)
+	dependents := make(map[int64][]int64)
+	for module, prerequisites := range requires {
+		if _, ok := pending[module]; !ok {
+			pending[module] = 0
+		}
+		for _, prerequisite := range prerequisites {
+			pending[module]++
+			if _, ok := pending[prerequisite]; !ok {
+				pending[prerequisite] = 0
+			}
+			dependents[prerequisite] = append(dependents[prerequisite], module)
+		}
+	}
+	var ready []int64
+	for id, count := range pending {
+		if count == 0 {
+			ready = append(ready, id)
+		}
+	}
+	order := make([]int64, 0, len(pending))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i] < ready[j] })
+		id := ready[0]
+		ready = ready[1:]
+		if id != root {
+			order = append(order, id)
+		}
+		for _, dependent := range dependents[id] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return order
+}
@@ -0,0 +1,346 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"time"
+)
+
+// Program is a named degree plan which places modules into numbered semesters.
+type Program struct {
+	ID                  int64           `json:"id"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	ProgramName         string          `json:"program_name"`
+	SemesterCount       int32           `json:"semester_count"`
+	MaxSemesterDuration int32           `json:"max_semester_duration"`
+	Modules             []ProgramModule `json:"modules"`
+	Version             int32           `json:"version"`
+}
+
+// ProgramModule places a single module into a semester of a program.
+type ProgramModule struct {
+	ModuleID int64 `json:"module_id"`
+	Semester int32 `json:"semester"`
+}
+
+// ProgramSemester and ProgramView describe the semester-by-semester layout of a
+// program, with the module_duration totals for each semester and the whole program.
+type ProgramSemester struct {
+	Semester      int32         `json:"semester"`
+	Modules       []*ModuleInfo `json:"modules"`
+	TotalDuration int32         `json:"total_duration"`
+}
+type ProgramView struct {
+	Program       *Program          `json:"program"`
+	Semesters     []ProgramSemester `json:"semesters"`
+	TotalDuration int32             `json:"total_duration"`
+}
+
+type ProgramModel struct {
+	DB *sql.DB
+}
+
+func ValidateProgram(v *validator.Validator, program *Program) {
+	v.Check(program.ProgramName != "", "program_name", "must be provided")
+	v.Check(len(program.ProgramName) <= 500, "program_name", "must not be more than 500 bytes long")
+	v.Check(program.SemesterCount >= 1, "semester_count", "must be at least 1")
+	v.Check(program.SemesterCount <= 16, "semester_count", "must not be more than 16")
+	v.Check(program.MaxSemesterDuration > 0, "max_semester_duration", "must be greater than zero")
+
+	moduleIDs := make([]int64, 0, len(program.Modules))
+	for _, placement := range program.Modules {
+		v.Check(placement.ModuleID > 0, "modules", "must only contain positive module ids")
+		v.Check(placement.Semester >= 1 && placement.Semester <= program.SemesterCount, "modules", "semester must be between 1 and semester_count")
+		moduleIDs = append(moduleIDs, placement.ModuleID)
+	}
+	v.Check(validator.Unique(moduleIDs), "modules", "must not contain duplicate modules")
+}
+
+// ValidateProgramDurations checks the placements of a program against the
+// module_duration of each module, as returned by ProgramModel.ModuleDurations.
+func ValidateProgramDurations(v *validator.Validator, program *Program, durations map[int64]int32) {
+	totals := make(map[int32]int32)
+	for _, placement := range program.Modules {
+		duration, ok := durations[placement.ModuleID]
+		if !ok {
+			v.AddError("modules", "must only reference existing modules")
+			continue
+		}
+		totals[placement.Semester] += duration
+	}
+	for semester := int32(1); semester <= program.SemesterCount; semester++ {
+		total := totals[semester]
+		v.Check(total <= program.MaxSemesterDuration, fmt.Sprintf("semester_%d", semester),
+			fmt.Sprintf("total module duration %d exceeds the maximum of %d", total, program.MaxSemesterDuration))
+	}
+}
+
+func (m ProgramModel) ModuleDurations(moduleIDs []int64) (map[int64]int32, error) {
+	durations := make(map[int64]int32)
+	if len(moduleIDs) == 0 {
+		return durations, nil
+	}
+	query := `SELECT id, module_duration FROM module_info WHERE id = ANY($1) AND deleted_at IS NULL`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(moduleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var duration int32
+		err := rows.Scan(&id, &duration)
+		if err != nil {
+			return nil, err
+		}
+		durations[id] = duration
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return durations, nil
+}
+func (m ProgramModel) Insert(program *Program) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO programs(program_name,semester_count,max_semester_duration) VALUES($1,$2,$3) RETURNING id,created_at,updated_at,version`
+	args := []any{program.ProgramName, program.SemesterCount, program.MaxSemesterDuration}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&program.ID, &program.CreatedAt, &program.UpdatedAt, &program.Version)
+	if err != nil {
+		return err
+	}
+	err = insertProgramModules(ctx, tx, program)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+func (m ProgramModel) Get(id int64) (*Program, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id,created_at,updated_at,program_name,semester_count,max_semester_duration,version FROM programs WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var program Program
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&program.ID, &program.CreatedAt, &program.UpdatedAt, &program.ProgramName, &program.SemesterCount, &program.MaxSemesterDuration, &program.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	err = m.loadModules(ctx, []*Program{&program})
+	if err != nil {
+		return nil, err
+	}
+	return &program, nil
+}
+
+// Update replaces the program's fields and its full set of module placements. The
+// version check on the programs row guards the placements as well.
+func (m ProgramModel) Update(program *Program) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE programs SET program_name = $1,semester_count = $2,max_semester_duration = $3,updated_at = NOW(),version = version +1 WHERE id=$4 AND version=$5 RETURNING updated_at,version`
+	args := []any{program.ProgramName, program.SemesterCount, program.MaxSemesterDuration, program.ID, program.Version}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&program.UpdatedAt, &program.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM program_modules WHERE program_id = $1`, program.ID)
+	if err != nil {
+		return err
+	}
+	err = insertProgramModules(ctx, tx, program)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+func (m ProgramModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM programs WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+func (m ProgramModel) GetAll(ProgramName string, filters Filters) ([]*Program, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id,created_at,updated_at,program_name,semester_count,max_semester_duration,version
+	FROM programs
+	WHERE (to_tsvector('simple', program_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	ORDER BY %s %s,id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, ProgramName, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	programs := []*Program{}
+	totalRecords := 0
+	for rows.Next() {
+		var program Program
+
+		err := rows.Scan(&totalRecords, &program.ID, &program.CreatedAt, &program.UpdatedAt, &program.ProgramName, &program.SemesterCount, &program.MaxSemesterDuration, &program.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		programs = append(programs, &program)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	err = m.loadModules(ctx, programs)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return programs, metadata, nil
+}
+
+// GetView returns the program laid out semester by semester, with every semester up
+// to SemesterCount present even when it has no modules yet.
+func (m ProgramModel) GetView(id int64) (*ProgramView, error) {
+	program, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+SELECT program_modules.semester, module_info.id, module_info.created_at, module_info.updated_at, module_info.module_name, module_info.module_duration, module_info.exam_type, module_info.capacity, module_info.version
+FROM program_modules
+INNER JOIN module_info ON module_info.id = program_modules.module_id
+WHERE program_modules.program_id = $1 AND module_info.deleted_at IS NULL
+ORDER BY program_modules.semester, module_info.module_name, module_info.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	view := &ProgramView{Program: program}
+	for semester := int32(1); semester <= program.SemesterCount; semester++ {
+		view.Semesters = append(view.Semesters, ProgramSemester{Semester: semester, Modules: []*ModuleInfo{}})
+	}
+	for rows.Next() {
+		var semester int32
+		var moduleInfo ModuleInfo
+		err := rows.Scan(&semester, &moduleInfo.ID, &moduleInfo.CreatedAt, &moduleInfo.UpdatedAt, &moduleInfo.ModuleName, &moduleInfo.ModuleDuration, &moduleInfo.ExamType, &moduleInfo.Capacity, &moduleInfo.Version)
+		if err != nil {
+			return nil, err
+		}
+		// Placements beyond SemesterCount can't be created through the API but are
+		// still shown rather than silently dropped.
+		for int(semester) > len(view.Semesters) {
+			view.Semesters = append(view.Semesters, ProgramSemester{Semester: int32(len(view.Semesters) + 1), Modules: []*ModuleInfo{}})
+		}
+		s := &view.Semesters[semester-1]
+		s.Modules = append(s.Modules, &moduleInfo)
+		s.TotalDuration += moduleInfo.ModuleDuration
+		view.TotalDuration += moduleInfo.ModuleDuration
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// loadModules fills in the module placements for a batch of programs with a single
+// query.
+func (m ProgramModel) loadModules(ctx context.Context, programs []*Program) error {
+	if len(programs) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Program, len(programs))
+	ids := make([]int64, 0, len(programs))
+	for _, program := range programs {
+		program.Modules = []ProgramModule{}
+		byID[program.ID] = program
+		ids = append(ids, program.ID)
+	}
+	query := `SELECT program_id, module_id, semester FROM program_modules WHERE program_id = ANY($1) ORDER BY semester, module_id`
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var programID int64
+		var placement ProgramModule
+		err := rows.Scan(&programID, &placement.ModuleID, &placement.Semester)
+		if err != nil {
+			return err
+		}
+		byID[programID].Modules = append(byID[programID].Modules, placement)
+	}
+	return rows.Err()
+}
+func insertProgramModules(ctx context.Context, tx *sql.Tx, program *Program) error {
+	if len(program.Modules) == 0 {
+		return nil
+	}
+	moduleIDs := make([]int64, len(program.Modules))
+	semesters := make([]int64, len(program.Modules))
+	for i, placement := range program.Modules {
+		moduleIDs[i] = placement.ModuleID
+		semesters[i] = int64(placement.Semester)
+	}
+	query := `
+INSERT INTO program_modules (program_id, module_id, semester)
+SELECT $1, unnest($2::bigint[]), unnest($3::integer[])`
+	_, err := tx.ExecContext(ctx, query, program.ID, pq.Array(moduleIDs), pq.Array(semesters))
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/validator"
+	"html"
+	"strings"
+	"time"
+)
+
+// SearchTypes are the kinds of record /v1/search covers, as they appear in the type
+// of each result.
+var SearchTypes = []string{"module", "department", "user"}
+
+// SearchResult is one match of a unified search. Title is the text that matched, as
+// plain text. Snippet is the same text as HTML: escaped, with the matching words
+// wrapped in <b></b>, so it can be rendered as it is. Email is only filled in for
+// users, and only for callers allowed to see it.
+type SearchResult struct {
+	Type    string  `json:"type"`
+	ID      int64   `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float32 `json:"rank"`
+	Email   string  `json:"email,omitempty"`
+}
+
+type SearchModel struct {
+	DB *sql.DB
+}
+
+// ts_headline marks the matching words with snippetStart and snippetStop, which are
+// removed from the text beforehand so that only its marks remain. highlightSnippet
+// turns them into <b></b> once the rest has been escaped.
+const (
+	snippetStart   = "\x01"
+	snippetStop    = "\x02"
+	snippetOptions = "StartSel=" + snippetStart + ", StopSel=" + snippetStop
+)
+
+// highlightSnippet turns a headline marked with snippetStart and snippetStop into
+// HTML.
+func highlightSnippet(headline string) string {
+	return strings.NewReplacer(snippetStart, "<b>", snippetStop, "</b>").Replace(html.EscapeString(headline))
+}
+
+func ValidateSearch(v *validator.Validator, q string, types []string) {
+	v.Check(q != "", "q", "must be provided")
+	v.Check(len(q) <= 500, "q", "must not be more than 500 bytes long")
+	v.Check(len(types) != 0, "types", "must contain at least one type")
+	v.Check(validator.Unique(types), "types", "must not contain duplicate values")
+	for _, t := range types {
+		v.Check(validator.PermittedValue(t, SearchTypes...), "types", "must only contain module, department or user")
+	}
+}
+
+// Search ranks the modules, departments and users of the given types matching q
+// together, best first. The documents searched are the same to_tsvector
+// expressions the list endpoints use, so the GIN indexes on them serve both.
+// Snippets are only built for the page returned.
+func (m SearchModel) Search(q string, types []string, showEmails bool, filters Filters) ([]*SearchResult, Metadata, error) {
+	query := `
+SELECT total, type, id, title, ts_headline('simple', translate(title, $7, ''), plainto_tsquery('simple', $1), $6), rank, email
+FROM (
+	SELECT count(*) OVER() AS total, matches.*
+	FROM (
+		SELECT 'module' AS type, id, module_name AS title,
+			ts_rank(to_tsvector('simple', module_name), plainto_tsquery('simple', $1)) AS rank, '' AS email
+		FROM module_info
+		WHERE 'module' = ANY($2) AND deleted_at IS NULL
+		AND to_tsvector('simple', module_name) @@ plainto_tsquery('simple', $1)
+		UNION ALL
+		SELECT 'department', id, department_name,
+			ts_rank(to_tsvector('simple', department_name), plainto_tsquery('simple', $1)), ''
+		FROM department_info
+		WHERE 'department' = ANY($2)
+		AND to_tsvector('simple', department_name) @@ plainto_tsquery('simple', $1)
+		UNION ALL
+		SELECT 'user', id, fname || ' ' || sname,
+			ts_rank(to_tsvector('simple', fname || ' ' || sname), plainto_tsquery('simple', $1)),
+			CASE WHEN $3 THEN email ELSE '' END
+		FROM user_info
+		WHERE 'user' = ANY($2) AND deleted_at IS NULL
+		AND to_tsvector('simple', fname || ' ' || sname) @@ plainto_tsquery('simple', $1)
+	) AS matches
+	ORDER BY rank DESC, type, id
+	LIMIT $4 OFFSET $5
+) AS page
+ORDER BY rank DESC, type, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q, pq.Array(types), showEmails, filters.limit(), filters.offset(), snippetOptions, snippetStart+snippetStop)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	results := []*SearchResult{}
+	totalRecords := 0
+	for rows.Next() {
+		var result SearchResult
+		err := rows.Scan(&totalRecords, &result.Type, &result.ID, &result.Title, &result.Snippet, &result.Rank, &result.Email)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		result.Snippet = highlightSnippet(result.Snippet)
+		results = append(results, &result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return results, metadata, nil
+}
@@ -0,0 +1,39 @@
+package data
+
+import "testing"
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		want     string
+	}{
+		{
+			name:     "plain match",
+			headline: "\x01Algebra\x02 basics",
+			want:     "<b>Algebra</b> basics",
+		},
+		{
+			name:     "script in a name",
+			headline: "\x01Eve\x02 <script>alert(1)</script>",
+			want:     "<b>Eve</b> &lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name:     "markup inside the match",
+			headline: "\x01<b>Bob</b>\x02",
+			want:     "<b>&lt;b&gt;Bob&lt;/b&gt;</b>",
+		},
+		{
+			name:     "quotes and ampersands",
+			headline: "R&D \"Lab\" \x01unit\x02",
+			want:     "R&amp;D &#34;Lab&#34; <b>unit</b>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.headline); got != tt.want {
+				t.Errorf("highlightSnippet(%q) = %q, want %q", tt.headline, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// statsTimeout is longer than the usual query timeout because the aggregates scan
+// whole tables. They are cached by the API, so they run rarely.
+const statsTimeout = 30 * time.Second
+
+// ExamTypeCount is the number of modules assessed by one exam type.
+type ExamTypeCount struct {
+	ExamType    string `json:"exam_type"`
+	DisplayName string `json:"display_name"`
+	Modules     int    `json:"modules"`
+}
+
+// DurationBucket is the number of modules of one duration.
+type DurationBucket struct {
+	ModuleDuration int32 `json:"module_duration"`
+	Modules        int   `json:"modules"`
+}
+
+// DurationStats summarises module durations: their average and a histogram with a
+// bucket for every duration in use.
+type DurationStats struct {
+	Modules   int              `json:"modules"`
+	Average   float64          `json:"average"`
+	Histogram []DurationBucket `json:"histogram"`
+}
+
+// StaffSizeBucket is the number of units whose staff count lies between MinStaff
+// and MaxStaff inclusive. MaxStaff is 0 for the open-ended last bucket.
+type StaffSizeBucket struct {
+	MinStaff    int `json:"min_staff"`
+	MaxStaff    int `json:"max_staff,omitempty"`
+	Departments int `json:"departments"`
+}
+
+// WeeklyRegistrations counts the users who registered in the week starting on Week,
+// and how many of them have since activated their account.
+type WeeklyRegistrations struct {
+	Week           time.Time `json:"week"`
+	Registered     int       `json:"registered"`
+	Activated      int       `json:"activated"`
+	ActivationRate float64   `json:"activation_rate"`
+}
+
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// ModulesByExamType counts the live modules created between from and to, either of
+// which may be zero to leave that end open, for every exam type in the catalogue.
+func (m StatsModel) ModulesByExamType(from, to time.Time) ([]*ExamTypeCount, error) {
+	query := `
+SELECT exam_types.code, exam_types.display_name, count(module_info.id)
+FROM exam_types
+LEFT JOIN module_info ON module_info.exam_type = exam_types.code
+	AND module_info.deleted_at IS NULL
+	AND (module_info.created_at >= $1 OR $1 IS NULL)
+	AND (module_info.created_at < $2 OR $2 IS NULL)
+GROUP BY exam_types.code, exam_types.display_name
+ORDER BY count(module_info.id) DESC, exam_types.code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []*ExamTypeCount{}
+	for rows.Next() {
+		var count ExamTypeCount
+		err := rows.Scan(&count.ExamType, &count.DisplayName, &count.Modules)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, &count)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// ModuleDurations summarises the durations of the live modules created between
+// from and to.
+func (m StatsModel) ModuleDurations(from, to time.Time) (*DurationStats, error) {
+	query := `
+SELECT module_duration, count(*)
+FROM module_info
+WHERE deleted_at IS NULL
+AND (created_at >= $1 OR $1 IS NULL)
+AND (created_at < $2 OR $2 IS NULL)
+GROUP BY module_duration
+ORDER BY module_duration`
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := &DurationStats{Histogram: []DurationBucket{}}
+	total := 0
+	for rows.Next() {
+		var bucket DurationBucket
+		err := rows.Scan(&bucket.ModuleDuration, &bucket.Modules)
+		if err != nil {
+			return nil, err
+		}
+		stats.Histogram = append(stats.Histogram, bucket)
+		stats.Modules += bucket.Modules
+		total += int(bucket.ModuleDuration) * bucket.Modules
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if stats.Modules > 0 {
+		stats.Average = float64(total) / float64(stats.Modules)
+	}
+	return stats, nil
+}
+
+// staffSizeBounds are the lower bounds of the staff size buckets.
+var staffSizeBounds = []int{0, 1, 5, 10, 20, 50}
+
+// DepartmentsByStaffSize counts the units in each staff size bucket, by their
+// current staff. Every bucket is returned, empty or not.
+func (m StatsModel) DepartmentsByStaffSize() ([]*StaffSizeBucket, error) {
+	query := `
+SELECT staff_quantity, count(*)
+FROM (SELECT ` + staffQuantityColumn + ` FROM department_info) AS sizes
+GROUP BY staff_quantity`
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	buckets := make([]*StaffSizeBucket, len(staffSizeBounds))
+	for i, bound := range staffSizeBounds {
+		buckets[i] = &StaffSizeBucket{MinStaff: bound}
+		if i+1 < len(staffSizeBounds) {
+			buckets[i].MaxStaff = staffSizeBounds[i+1] - 1
+		}
+	}
+	for rows.Next() {
+		var staff, departments int
+		err := rows.Scan(&staff, &departments)
+		if err != nil {
+			return nil, err
+		}
+		i := len(staffSizeBounds) - 1
+		for staff < staffSizeBounds[i] {
+			i--
+		}
+		buckets[i].Departments += departments
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// WeeklyRegistrations counts the users registering each week between from and to,
+// and how many of them have activated. Weeks start on Monday, in UTC, and weeks
+// without registrations are left out.
+func (m StatsModel) WeeklyRegistrations(from, to time.Time) ([]*WeeklyRegistrations, error) {
+	query := `
+SELECT date_trunc('week', created_at AT TIME ZONE 'UTC') AS week, count(*), count(*) FILTER (WHERE activated)
+FROM user_info
+WHERE deleted_at IS NULL
+AND (created_at >= $1 OR $1 IS NULL)
+AND (created_at < $2 OR $2 IS NULL)
+GROUP BY week
+ORDER BY week`
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	weeks := []*WeeklyRegistrations{}
+	for rows.Next() {
+		var week WeeklyRegistrations
+		err := rows.Scan(&week.Week, &week.Registered, &week.Activated)
+		if err != nil {
+			return nil, err
+		}
+		week.Week = week.Week.UTC()
+		if week.Registered > 0 {
+			week.ActivationRate = float64(week.Activated) / float64(week.Registered)
+		}
+		weeks = append(weeks, &week)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return weeks, nil
+}
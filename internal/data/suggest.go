@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"golangHW.darkhanomirbay/internal/validator"
+	"strings"
+	"time"
+)
+
+// suggestTimeout is kept short because clients call the suggest endpoints on every
+// keystroke; a slow suggestion is worth less than none.
+const suggestTimeout = time.Second
+
+// Suggestion is a name offered for a partly typed one. A name starting with what was
+// typed scores 1; any other scores its trigram word similarity to it.
+type Suggestion struct {
+	ID    int64   `json:"id"`
+	Text  string  `json:"text"`
+	Score float32 `json:"score"`
+}
+
+func ValidateSuggest(v *validator.Validator, prefix string, limit int) {
+	v.Check(strings.TrimSpace(prefix) != "", "prefix", "must be provided")
+	v.Check(len(prefix) <= 100, "prefix", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
+}
+
+// likePrefix is the LIKE pattern matching strings that start with prefix, ignoring
+// case.
+func likePrefix(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(strings.ToLower(prefix)) + "%"
+}
+
+// suggest runs a suggestion query over name, an expression covered by the trigram
+// and prefix indexes, for the rows of table matching where.
+func suggest(db *sql.DB, table, name, where, prefix string, limit int) ([]*Suggestion, error) {
+	query := `
+SELECT id, ` + name + `,
+	CASE WHEN lower(` + name + `) LIKE $2 THEN 1 ELSE word_similarity(lower($1), lower(` + name + `)) END AS score
+FROM ` + table + `
+WHERE ` + where + `
+AND (lower(` + name + `) LIKE $2 OR lower($1) <% lower(` + name + `))
+ORDER BY score DESC, ` + name + `, id
+LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), suggestTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, strings.TrimSpace(prefix), likePrefix(strings.TrimSpace(prefix)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Text, &suggestion.Score)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// Suggest offers up to limit module names for prefix, as typed so far.
+func (m ModuleInfoModel) Suggest(prefix string, limit int) ([]*Suggestion, error) {
+	return suggest(m.DB, "module_info", "module_name", "deleted_at IS NULL", prefix, limit)
+}
+
+// Suggest offers up to limit full names for prefix, as typed so far.
+func (m *UserInfoModel) Suggest(prefix string, limit int) ([]*Suggestion, error) {
+	return suggest(m.DB, "user_info", "(fname || ' ' || sname)", "deleted_at IS NULL", prefix, limit)
+}
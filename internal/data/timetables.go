@@ -0,0 +1,340 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"golangHW.darkhanomirbay/internal/timetable"
+	"golangHW.darkhanomirbay/internal/validator"
+	"time"
+)
+
+const (
+	TimetableDraft     = "draft"
+	TimetablePublished = "published"
+	TimetableArchived  = "archived"
+)
+
+// Timetable is one generated version of a named weekly timetable. Generating again
+// under the same name creates the next version; publishing a version archives the
+// version that was published before it.
+type Timetable struct {
+	ID          int64                   `json:"id"`
+	CreatedAt   time.Time               `json:"created_at"`
+	Name        string                  `json:"timetable_name"`
+	Version     int32                   `json:"version"`
+	Status      string                  `json:"status"`
+	PublishedAt *time.Time              `json:"published_at,omitempty"`
+	Complete    bool                    `json:"complete"`
+	Unsatisfied []timetable.Unsatisfied `json:"unsatisfied"`
+	Entries     []TimetableEntry        `json:"entries,omitempty"`
+}
+type TimetableEntry struct {
+	ModuleID  int64  `json:"module_id"`
+	Lecture   int    `json:"lecture"`
+	TeacherID int64  `json:"teacher_id"`
+	Room      string `json:"room"`
+	Day       int    `json:"day"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// These bound the size of a timetable problem, and so the time and memory the
+// solver can take over one request.
+const (
+	maxTimetableTeachers = 100
+	maxTimetableLectures = 500
+	// maxTimetableOptions bounds the placements (slot, room, teacher) considered
+	// across all modules.
+	maxTimetableOptions = 1_000_000
+)
+
+type TimetableModel struct {
+	DB *sql.DB
+}
+
+func ValidateTimetableProblem(v *validator.Validator, problem timetable.Problem) {
+	v.Check(len(problem.Modules) != 0, "module_ids", "must contain at least one module id")
+	v.Check(len(problem.Slots) != 0, "slots", "must contain at least one slot")
+	v.Check(len(problem.Slots) <= 100, "slots", "must not contain more than 100 slots")
+	starts := make([]time.Time, len(problem.Slots))
+	ends := make([]time.Time, len(problem.Slots))
+	for i, slot := range problem.Slots {
+		v.Check(slot.Day >= 1 && slot.Day <= 7, "slots", "day must be between 1 and 7")
+		start, errStart := time.Parse("15:04", slot.Start)
+		end, errEnd := time.Parse("15:04", slot.End)
+		v.Check(errStart == nil && errEnd == nil, "slots", "start and end must be in HH:MM format")
+		v.Check(end.After(start), "slots", "end must be after start")
+		starts[i], ends[i] = start, end
+	}
+	// The solver treats different slots as different times, so slots that overlap
+	// could double-book a room or teacher.
+	for i := range problem.Slots {
+		for j := i + 1; j < len(problem.Slots); j++ {
+			overlap := problem.Slots[i].Day == problem.Slots[j].Day && starts[i].Before(ends[j]) && starts[j].Before(ends[i])
+			v.Check(!overlap, "slots", "must not overlap")
+		}
+	}
+	v.Check(len(problem.Rooms) != 0, "rooms", "must contain at least one room")
+	v.Check(len(problem.Rooms) <= 100, "rooms", "must not contain more than 100 rooms")
+	names := make([]string, 0, len(problem.Rooms))
+	for _, room := range problem.Rooms {
+		v.Check(room.Name != "", "rooms", "name must be provided")
+		v.Check(len(room.Name) <= 255, "rooms", "name must not be more than 255 bytes long")
+		v.Check(room.Capacity > 0, "rooms", "capacity must be greater than zero")
+		names = append(names, room.Name)
+	}
+	v.Check(validator.Unique(names), "rooms", "must not contain duplicate names")
+	v.Check(len(problem.Teachers) <= maxTimetableTeachers, "teachers", fmt.Sprintf("must not contain more than %d teachers", maxTimetableTeachers))
+	ids := make([]int64, 0, len(problem.Teachers))
+	for _, teacher := range problem.Teachers {
+		v.Check(teacher.ID > 0, "teachers", "user_id must be a positive number")
+		v.Check(len(teacher.Available) <= len(problem.Slots), "teachers", "available must not contain more entries than there are slots")
+		v.Check(validator.Unique(teacher.Available), "teachers", "available must not contain duplicate slots")
+		for _, slot := range teacher.Available {
+			v.Check(slot >= 0 && slot < len(problem.Slots), "teachers", "available must only contain indexes into slots")
+		}
+		ids = append(ids, teacher.ID)
+	}
+	v.Check(validator.Unique(ids), "teachers", "must not contain duplicate users")
+	lectures, options := 0, 0
+	for _, module := range problem.Modules {
+		lectures += module.Lectures
+		options += len(module.Teachers) * len(problem.Slots) * len(problem.Rooms)
+	}
+	v.Check(lectures <= maxTimetableLectures, "module_ids", fmt.Sprintf("must not need more than %d lectures in total; raise credits_per_lecture or timetable fewer modules", maxTimetableLectures))
+	v.Check(options <= maxTimetableOptions, "teachers", "too many combinations of teachers, slots and rooms; assign fewer teachers per module")
+}
+
+// LoadModules returns the modules with the given ids ordered by id.
+func (m TimetableModel) LoadModules(moduleIDs []int64) ([]*ModuleInfo, error) {
+	query := `
+SELECT id, created_at, updated_at, module_name, module_duration, exam_type, capacity, version
+FROM module_info
+WHERE id = ANY($1) AND deleted_at IS NULL
+ORDER BY id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(moduleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanModuleInfos(rows)
+}
+
+// Clashes returns the pairs of modules which share students, either because they sit
+// in the same semester of a program or because a student is enrolled in both.
+func (m TimetableModel) Clashes(moduleIDs []int64) ([][2]int64, error) {
+	query := `
+SELECT a.module_id, b.module_id
+FROM program_modules a
+INNER JOIN program_modules b ON b.program_id = a.program_id AND b.semester = a.semester AND b.module_id > a.module_id
+WHERE a.module_id = ANY($1) AND b.module_id = ANY($1)
+UNION
+SELECT a.module_id, b.module_id
+FROM enrollments a
+INNER JOIN enrollments b ON b.user_id = a.user_id AND b.module_id > a.module_id
+WHERE a.module_id = ANY($1) AND b.module_id = ANY($1)
+AND a.status = 'enrolled' AND b.status = 'enrolled'`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(moduleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clashes := [][2]int64{}
+	for rows.Next() {
+		var clash [2]int64
+		err := rows.Scan(&clash[0], &clash[1])
+		if err != nil {
+			return nil, err
+		}
+		clashes = append(clashes, clash)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return clashes, nil
+}
+
+// Insert stores a new draft version of the named timetable. The advisory lock keeps
+// two concurrent generations from picking the same version number.
+func (m TimetableModel) Insert(t *Timetable) error {
+	unsatisfied, err := json.Marshal(t.Unsatisfied)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, t.Name)
+	if err != nil {
+		return err
+	}
+	query := `
+INSERT INTO timetables (timetable_name, version, status, complete, unsatisfied)
+SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4 FROM timetables WHERE timetable_name = $1
+RETURNING id, created_at, version, status`
+	err = tx.QueryRowContext(ctx, query, t.Name, TimetableDraft, t.Complete, unsatisfied).Scan(&t.ID, &t.CreatedAt, &t.Version, &t.Status)
+	if err != nil {
+		return err
+	}
+	query = `
+INSERT INTO timetable_entries (timetable_id, module_id, lecture, teacher_id, room, day, start_time, end_time)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	for _, entry := range t.Entries {
+		args := []any{t.ID, entry.ModuleID, entry.Lecture, entry.TeacherID, entry.Room, entry.Day, entry.StartTime, entry.EndTime}
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			switch {
+			case isForeignKeyViolation(err):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+func (m TimetableModel) Get(id int64) (*Timetable, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id,created_at,timetable_name,version,status,published_at,complete,unsatisfied FROM timetables WHERE id=$1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	t, err := scanTimetable(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	query = `
+SELECT module_id, lecture, COALESCE(teacher_id, 0), room, day, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI')
+FROM timetable_entries
+WHERE timetable_id = $1
+ORDER BY day, start_time, room`
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	t.Entries = []TimetableEntry{}
+	for rows.Next() {
+		var entry TimetableEntry
+		err := rows.Scan(&entry.ModuleID, &entry.Lecture, &entry.TeacherID, &entry.Room, &entry.Day, &entry.StartTime, &entry.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		t.Entries = append(t.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetAll lists timetables without their entries.
+func (m TimetableModel) GetAll(name string, status string, filters Filters) ([]*Timetable, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id,created_at,timetable_name,version,status,published_at,complete,unsatisfied
+	FROM timetables
+	WHERE (timetable_name = $1 OR $1 = '')
+	AND (status = $2 OR $2 = '')
+	ORDER BY %s %s,id ASC
+	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	timetables := []*Timetable{}
+	totalRecords := 0
+	for rows.Next() {
+		var t Timetable
+		var unsatisfied []byte
+		err := rows.Scan(&totalRecords, &t.ID, &t.CreatedAt, &t.Name, &t.Version, &t.Status, &t.PublishedAt, &t.Complete, &unsatisfied)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		err = json.Unmarshal(unsatisfied, &t.Unsatisfied)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		timetables = append(timetables, &t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return timetables, metadata, nil
+}
+
+// Publish marks the timetable as published and archives whichever version of the same
+// timetable was published before.
+func (m TimetableModel) Publish(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRowContext(ctx, `SELECT timetable_name FROM timetables WHERE id = $1 FOR UPDATE`, id).Scan(&name)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, name)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE timetables SET status = 'archived' WHERE timetable_name = $1 AND status = 'published' AND id <> $2`
+	_, err = tx.ExecContext(ctx, query, name, id)
+	if err != nil {
+		return err
+	}
+	query = `UPDATE timetables SET status = 'published', published_at = COALESCE(published_at, NOW()) WHERE id = $1`
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanTimetable(row *sql.Row) (*Timetable, error) {
+	var t Timetable
+	var unsatisfied []byte
+	err := row.Scan(&t.ID, &t.CreatedAt, &t.Name, &t.Version, &t.Status, &t.PublishedAt, &t.Complete, &unsatisfied)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(unsatisfied, &t.Unsatisfied)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
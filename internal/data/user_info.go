@@ -1,12 +1,16 @@
 package data
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"golangHW.darkhanomirbay/internal/filter"
 	"golangHW.darkhanomirbay/internal/validator"
 	"time"
 )
@@ -23,16 +27,17 @@ func (u *UserInfo) IsAnonymous() bool {
 }
 
 type UserInfo struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Name      string    `json:"name"`
-	Surname   string    `json:"surname"`
-	Email     string    `json:"email"`
-	Password  password  `json:"-"`
-	Role      string    `json:"role"`
-	Activated bool      `json:"activated"`
-	Version   int       `json:"-"`
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Name      string     `json:"name"`
+	Surname   string     `json:"surname"`
+	Email     string     `json:"email,omitempty"`
+	Password  password   `json:"-"`
+	Role      string     `json:"role"`
+	Activated bool       `json:"activated"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"-"`
 }
 type password struct {
 	plaintext *string
@@ -62,7 +67,7 @@ func (m UserInfoModel) Insert(user *UserInfo) error {
 }
 func (m UserInfoModel) GetByEmail(email string) (*UserInfo, error) {
 	query := `SELECT id, created_at, updated_at,fname,sname, email, password_hash, user_role,activated, version
-FROM user_info WHERE email=$1`
+FROM user_info WHERE email=$1 AND deleted_at IS NULL`
 	var user UserInfo
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -89,7 +94,7 @@ FROM user_info WHERE email=$1`
 	return &user, nil
 }
 func (m UserInfoModel) Update(user *UserInfo) error {
-	query := `UPDATE user_info SET fname=$1,sname=$2,email=$3,password_hash=$4,user_role=$5,activated=$6,version=version + 1 WHERE id=$7 AND version=$8`
+	query := `UPDATE user_info SET fname=$1,sname=$2,email=$3,password_hash=$4,user_role=$5,activated=$6,version=version + 1 WHERE id=$7 AND version=$8 AND deleted_at IS NULL RETURNING version`
 	args := []any{
 		user.Name,
 		user.Surname,
@@ -126,7 +131,32 @@ func (p *password) Set(plaintextPassword string) error {
 	p.hash = hash
 	return nil
 }
+
+// unusablePrefix marks a stored hash that no password matches. bcrypt hashes start
+// with "$", so it cannot collide with one.
+var unusablePrefix = []byte("!")
+
+// SetUnusable gives an account a random hash that no password matches, for users
+// created on someone else's behalf who choose their password when they activate.
+func (p *password) SetUnusable() error {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+	p.plaintext = nil
+	p.hash = append(append([]byte{}, unusablePrefix...), hex.EncodeToString(b)...)
+	return nil
+}
+
+// Usable reports whether a password has been set, as opposed to SetUnusable.
+func (p *password) Usable() bool {
+	return !bytes.HasPrefix(p.hash, unusablePrefix)
+}
 func (p *password) Matches(plaintextPassword string) (bool, error) {
+	if !p.Usable() {
+		return false, nil
+	}
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
 		switch {
@@ -149,10 +179,7 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 
 }
 func ValidateUser(v *validator.Validator, user *UserInfo) {
-	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
-
-	ValidateEmail(v, user.Email)
+	validateUserDetails(v, user)
 	if user.Password.plaintext != nil {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
 	}
@@ -160,6 +187,23 @@ func ValidateUser(v *validator.Validator, user *UserInfo) {
 		panic("missing password hash for user")
 	}
 }
+
+// ValidateUserInput applies the checks of ValidateUser to a user whose password has
+// not been hashed yet. Bulk imports use it so rows can be validated, and dry runs
+// answered, without paying for a bcrypt hash per row. An empty plaintextPassword
+// stands for an account whose user sets the password on activation.
+func ValidateUserInput(v *validator.Validator, user *UserInfo, plaintextPassword string) {
+	validateUserDetails(v, user)
+	if plaintextPassword != "" {
+		ValidatePasswordPlaintext(v, plaintextPassword)
+	}
+}
+func validateUserDetails(v *validator.Validator, user *UserInfo) {
+	v.Check(user.Name != "", "name", "must be provided")
+	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	ValidateEmail(v, user.Email)
+}
 func (m UserInfoModel) GetForToken(tokenScope, tokenPlaintext string) (*UserInfo, error) {
 	// Calculate the SHA-256 hash of the plaintext token provided by the client.
 	// Remember that this returns a byte *array* with length 32, not a slice.
@@ -172,7 +216,8 @@ INNER JOIN tokens
 ON user_info.id = tokens.user_id
 WHERE tokens.hash = $1
 AND tokens.scope = $2
-AND tokens.expiry > $3`
+AND tokens.expiry > $3
+AND user_info.deleted_at IS NULL`
 	// Create a slice containing the query arguments. Notice how we use the [:] operator
 	// to get a slice containing the token hash, rather than passing in the array (which
 	// is not supported by the pq driver), and that we pass the current time as the
@@ -210,7 +255,7 @@ func (m *UserInfoModel) Get(id int64) (*UserInfo, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := `SELECT id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,version FROM user_info WHERE id=$1`
+	query := `SELECT id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,version FROM user_info WHERE id=$1 AND deleted_at IS NULL`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	var user UserInfo
@@ -234,14 +279,24 @@ func (m *UserInfoModel) Get(id int64) (*UserInfo, error) {
 	}
 	return &user, nil
 }
-func (m *UserInfoModel) Delete(id int64) error {
+
+// Delete moves the user to the trash. A non-zero version must match the user's
+// current one, otherwise ErrEditConflict is returned.
+func (m *UserInfoModel) Delete(id int64, version int) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	query := `DELETE FROM user_info WHERE id=$1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// The row is only marked as deleted so it can be restored from the trash, but its
+	// tokens go straight away so a deleted user cannot keep using the API.
+	query := `UPDATE user_info SET deleted_at = NOW() WHERE id=$1 AND (version=$2 OR $2 = 0) AND deleted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -250,32 +305,45 @@ func (m *UserInfoModel) Delete(id int64) error {
 		return err
 	}
 	if rowsAffected == 0 {
+		if version != 0 {
+			var exists bool
+			err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM user_info WHERE id=$1 AND deleted_at IS NULL)`, id).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrEditConflict
+			}
+		}
 		return ErrRecordNotFound
 	}
-	return nil
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
-func (m *UserInfoModel) GetAll(Fname string, Sname string, filters Filters) ([]*UserInfo, Metadata, error) {
-	query := fmt.Sprintf(`SELECT count(*) OVER(), id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,version
+func (m *UserInfoModel) GetAll(Fname string, Sname string, within int64, filters Filters) ([]*UserInfo, Metadata, error) {
+	where, args := filters.where(userInfoSearch, Fname, Sname, within)
+	query, args := filters.pageQuery(`SELECT `+filters.countColumn()+`, id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,version
 	FROM user_info
-	WHERE (to_tsvector('simple', fname) @@ plainto_tsquery('simple', $1) OR $1 = '')
-	AND (to_tsvector('simple', sname) @@ plainto_tsquery('simple', $2) OR $2 = '')
-	ORDER BY %s %s,id ASC
-	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	WHERE `+where, args...)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, Fname, Sname, filters.limit(), filters.offset())
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, Metadata{}, err
+		return nil, Metadata{}, filters.pageQueryError(err)
 	}
 	defer rows.Close()
 	userInfos := []*UserInfo{}
 	totalRecords := 0
+	var sortValue any
 	for rows.Next() {
 		var user UserInfo
 
-		err := rows.Scan(&totalRecords, &user.ID,
+		err := rows.Scan(&sortValue, &totalRecords, &user.ID,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.Name,
@@ -291,16 +359,86 @@ func (m *UserInfoModel) GetAll(Fname string, Sname string, filters Filters) ([]*
 		userInfos = append(userInfos, &user)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		return nil, Metadata{}, filters.pageQueryError(err)
 	}
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	var lastID int64
+	if len(userInfos) > 0 {
+		lastID = userInfos[len(userInfos)-1].ID
+	}
+	metadata := filters.pageMetadata(totalRecords, len(userInfos), sortValue, lastID)
 
 	return userInfos, metadata, nil
 }
+
+// Export streams every user matching the same search as GetAll to fn, in sort order,
+// without loading them all into memory. It stops at the first error fn returns. The
+// password hash is never selected, so the users passed to fn carry none.
+func (m *UserInfoModel) Export(Fname string, Sname string, within int64, filters Filters, fn func(*UserInfo) error) error {
+	where, args := filters.where(userInfoSearch, Fname, Sname, within)
+	query := fmt.Sprintf(`SELECT id,created_at,updated_at,fname,sname,email,user_role,activated
+	FROM user_info
+	WHERE `+where+`
+	ORDER BY %s %s,id ASC`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userInfo UserInfo
+		err := rows.Scan(&userInfo.ID, &userInfo.CreatedAt, &userInfo.UpdatedAt, &userInfo.Name, &userInfo.Surname, &userInfo.Email, &userInfo.Role, &userInfo.Activated)
+		if err != nil {
+			return err
+		}
+		if err := fn(&userInfo); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// UserInfoFilterFields are the fields the user list and export can be filtered on.
+// The password hash is deliberately not one of them.
+var UserInfoFilterFields = filter.Fields{
+	"id":         {Column: "id", Type: filter.Int},
+	"fname":      {Column: "fname", Type: filter.String},
+	"sname":      {Column: "sname", Type: filter.String},
+	"email":      {Column: "email", Type: filter.String},
+	"role":       {Column: "user_role", Type: filter.String},
+	"activated":  {Column: "activated", Type: filter.Bool},
+	"created_at": {Column: "created_at", Type: filter.Time},
+	"updated_at": {Column: "updated_at", Type: filter.Time},
+}
+
+// UserInfoFilterFieldsWithoutEmail are UserInfoFilterFields less email, for callers
+// not allowed to see addresses, who could otherwise recover them by filtering.
+var UserInfoFilterFieldsWithoutEmail = func() filter.Fields {
+	fields := make(filter.Fields, len(UserInfoFilterFields))
+	for name, field := range UserInfoFilterFields {
+		if name != "email" {
+			fields[name] = field
+		}
+	}
+	return fields
+}()
+
+// userInfoSearch is the WHERE clause behind GetAll and Export. It takes the first
+// name, surname and unit id as $1, $2 and $3.
+var userInfoSearch = `deleted_at IS NULL
+	AND (to_tsvector('simple', fname) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	AND (to_tsvector('simple', sname) @@ plainto_tsquery('simple', $2) OR $2 = '')
+	AND (id IN (
+		SELECT user_id FROM department_staff WHERE department_id IN (` + WithinUnit("$3") + `)
+	) OR $3 = 0)`
+
 func (m *UserInfoModel) GetAllNonActivated(Fname string, Sname string, filters Filters) ([]*UserInfo, Metadata, error) {
 	query := fmt.Sprintf(`SELECT count(*) OVER(), id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,version
 	FROM user_info
-	WHERE activated=false AND 
+	WHERE activated=false AND deleted_at IS NULL AND
 	    (to_tsvector('simple', fname) @@ plainto_tsquery('simple', $1) OR $1 = '')
 	AND (to_tsvector('simple', sname) @@ plainto_tsquery('simple', $2) OR $2 = '')
 	ORDER BY %s %s,id ASC
@@ -348,7 +486,7 @@ func (m *UserInfoModel) GetAllNoActiv() ([]*UserInfo, error) {
 	query2 := `SELECT u.id, u.created_at, u.updated_at, u.fname, u.sname, u.email, u.password_hash, u.user_role, u.activated, u.version
 FROM user_info u
 JOIN tokens t ON u.id = t.user_id
-WHERE u.activated = false
+WHERE u.activated = false AND u.deleted_at IS NULL
 AND t.expiry < NOW();
 `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -383,3 +521,92 @@ AND t.expiry < NOW();
 	}
 	return userInfos, nil
 }
+
+// GetDeleted lists the users in the trash.
+func (m *UserInfoModel) GetDeleted(filters Filters) ([]*UserInfo, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,deleted_at,version
+	FROM user_info
+	WHERE deleted_at IS NOT NULL
+	ORDER BY %s %s,id ASC
+	LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	userInfos := []*UserInfo{}
+	totalRecords := 0
+	for rows.Next() {
+		var user UserInfo
+
+		err := rows.Scan(&totalRecords, &user.ID,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Name,
+			&user.Surname,
+			&user.Email,
+			&user.Password.hash,
+			&user.Role,
+			&user.Activated,
+			&user.DeletedAt,
+			&user.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		userInfos = append(userInfos, &user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return userInfos, metadata, nil
+}
+
+// Restore takes a user back out of the trash. Tokens removed on deletion are not
+// brought back, so the user has to authenticate again.
+func (m *UserInfoModel) Restore(id int64) (*UserInfo, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `UPDATE user_info SET deleted_at = NULL,version = version + 1 WHERE id=$1 AND deleted_at IS NOT NULL
+	RETURNING id,created_at,updated_at,fname,sname,email,password_hash,user_role,activated,version`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var user UserInfo
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&user.ID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Name,
+		&user.Surname,
+		&user.Email,
+		&user.Password.hash,
+		&user.Role,
+		&user.Activated,
+		&user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// PurgeDeleted permanently removes users that were deleted before the cutoff.
+func (m *UserInfoModel) PurgeDeleted(before time.Time) (int64, error) {
+	query := `DELETE FROM user_info WHERE deleted_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,367 @@
+// Package filter parses the filter expressions accepted by the list endpoints and
+// compiles them into parameterised SQL. An expression compares fields with literal
+// values and combines the comparisons with and, or, not and parentheses:
+//
+//	module_duration>=5 and exam_type in ("written","oral") and created_at>2026-01-01
+//	capacity between 10 and 50 or not (module_name = "Algebra")
+//
+// Only fields in the whitelist passed to Parse may be used, and literals are
+// checked against the field's type, range included, while parsing, so a parsed
+// expression always compiles to valid SQL and runs without conversion errors.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLength is the longest expression Parse accepts, in bytes.
+	MaxLength = 2000
+	// maxComparisons bounds the number of comparisons in one expression.
+	maxComparisons = 50
+	// maxInValues bounds the number of values in one in list.
+	maxInValues = 100
+	// maxDepth bounds how deeply parentheses and not may nest.
+	maxDepth = 20
+)
+
+// Type is the type of a filterable field. It decides which literals the field can
+// be compared with and which operators apply.
+type Type int
+
+const (
+	String Type = iota
+	// Int is a BIGINT column.
+	Int
+	// Int32 is an INTEGER column; larger literals would fail in the database.
+	Int32
+	Time
+	Bool
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int, Int32:
+		return "an integer"
+	case Time:
+		return "a date or timestamp"
+	case Bool:
+		return "true or false"
+	default:
+		return "a string"
+	}
+}
+
+// Field is a filterable field. Column is the SQL expression the field compiles to.
+type Field struct {
+	Column string
+	Type   Type
+}
+
+// Fields is the whitelist of fields a resource can be filtered on, by name.
+type Fields map[string]Field
+
+// Error is a syntax or type error in an expression. Pos is the byte offset, counting
+// from 1, at which it was found.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Expr is a parsed filter expression.
+type Expr interface {
+	compile(c *compiler) string
+}
+
+type logicalExpr struct {
+	op          string
+	left, right Expr
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+// comparison compares a field with one value (=, !=, <, <=, >, >=), a list of
+// values (in) or a pair of bounds (between).
+type comparison struct {
+	field  Field
+	op     string
+	values []any
+}
+
+// Parse parses expression s, checking every field against fields.
+func Parse(s string, fields Fields) (Expr, error) {
+	if len(s) > MaxLength {
+		return nil, &Error{Pos: MaxLength + 1, Msg: fmt.Sprintf("expression is longer than %d bytes", MaxLength)}
+	}
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	return expr, nil
+}
+
+// Compile returns the SQL for expr, with its literals as placeholders numbered from
+// firstArg, and the arguments for those placeholders.
+func Compile(expr Expr, firstArg int) (string, []any) {
+	c := &compiler{next: firstArg}
+	return expr.compile(c), c.args
+}
+
+type compiler struct {
+	next int
+	args []any
+}
+
+func (c *compiler) arg(value any) string {
+	c.args = append(c.args, value)
+	c.next++
+	return fmt.Sprintf("$%d", c.next-1)
+}
+
+func (e *logicalExpr) compile(c *compiler) string {
+	return "(" + e.left.compile(c) + " " + strings.ToUpper(e.op) + " " + e.right.compile(c) + ")"
+}
+
+func (e *notExpr) compile(c *compiler) string {
+	return "(NOT " + e.expr.compile(c) + ")"
+}
+
+func (e *comparison) compile(c *compiler) string {
+	switch e.op {
+	case "in":
+		placeholders := make([]string, len(e.values))
+		for i, value := range e.values {
+			placeholders[i] = c.arg(value)
+		}
+		return fmt.Sprintf("(%s IN (%s))", e.field.Column, strings.Join(placeholders, ", "))
+	case "between":
+		return fmt.Sprintf("(%s BETWEEN %s AND %s)", e.field.Column, c.arg(e.values[0]), c.arg(e.values[1]))
+	case "!=":
+		return fmt.Sprintf("(%s <> %s)", e.field.Column, c.arg(e.values[0]))
+	default:
+		return fmt.Sprintf("(%s %s %s)", e.field.Column, e.op, c.arg(e.values[0]))
+	}
+}
+
+type parser struct {
+	tokens      []token
+	pos         int
+	fields      Fields
+	comparisons int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.advance()
+	if t.kind != kind {
+		return t, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected %s, found %s", what, t)}
+	}
+	return t, nil
+}
+
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.advance()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.advance()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary(depth int) (Expr, error) {
+	t := p.peek()
+	if depth > maxDepth {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expression is nested more than %d levels deep", maxDepth)}
+	}
+	switch {
+	case t.isKeyword("not"):
+		p.advance()
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: expr}, nil
+	case t.kind == tokenLParen:
+		p.advance()
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(tokenRParen, `")"`)
+		if err != nil {
+			return nil, err
+		}
+		return expr, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	name, err := p.expect(tokenIdent, "a field name")
+	if err != nil {
+		return nil, err
+	}
+	field, ok := p.fields[name.text]
+	if !ok {
+		return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("unknown field %q", name.text)}
+	}
+	p.comparisons++
+	if p.comparisons > maxComparisons {
+		return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("expression has more than %d comparisons", maxComparisons)}
+	}
+
+	op := p.advance()
+	switch {
+	case op.kind == tokenOperator:
+		if field.Type == Bool && op.text != "=" && op.text != "!=" {
+			return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("%s can only be compared with = or !=", name.text)}
+		}
+		value, err := p.parseValue(name.text, field)
+		if err != nil {
+			return nil, err
+		}
+		return &comparison{field: field, op: op.text, values: []any{value}}, nil
+	case op.isKeyword("in"):
+		_, err = p.expect(tokenLParen, `"(" after in`)
+		if err != nil {
+			return nil, err
+		}
+		var values []any
+		for {
+			value, err := p.parseValue(name.text, field)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if len(values) > maxInValues {
+				return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("in list has more than %d values", maxInValues)}
+			}
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.advance()
+		}
+		_, err = p.expect(tokenRParen, `"," or ")"`)
+		if err != nil {
+			return nil, err
+		}
+		return &comparison{field: field, op: "in", values: values}, nil
+	case op.isKeyword("between"):
+		if field.Type == Bool {
+			return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("%s can only be compared with = or !=", name.text)}
+		}
+		low, err := p.parseValue(name.text, field)
+		if err != nil {
+			return nil, err
+		}
+		and := p.advance()
+		if !and.isKeyword("and") {
+			return nil, &Error{Pos: and.pos, Msg: fmt.Sprintf("expected and, found %s", and)}
+		}
+		high, err := p.parseValue(name.text, field)
+		if err != nil {
+			return nil, err
+		}
+		return &comparison{field: field, op: "between", values: []any{low, high}}, nil
+	default:
+		return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("expected an operator after %s, found %s", name.text, op)}
+	}
+}
+
+// parseValue reads a literal and converts it to the type of field.
+func (p *parser) parseValue(name string, field Field) (any, error) {
+	t := p.advance()
+	if t.kind != tokenString && t.kind != tokenIdent && t.kind != tokenLiteral {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected a value, found %s", t)}
+	}
+	var value any
+	var err error
+	switch field.Type {
+	case Int:
+		value, err = strconv.ParseInt(t.text, 10, 64)
+	case Int32:
+		value, err = strconv.ParseInt(t.text, 10, 32)
+	case Bool:
+		value, err = strconv.ParseBool(t.text)
+	case Time:
+		value, err = parseTime(t.text)
+	default:
+		value = t.text
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("%s is out of range for %s", t.text, name)}
+	}
+	if err != nil {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("%s must be compared with %s", name, field.Type)}
+	}
+	return value, nil
+}
+
+// timeLayouts are the formats accepted for Time fields. A bare date means midnight
+// UTC at the start of that day.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+func parseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,183 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testFields = Fields{
+	"id":              {Column: "id", Type: Int},
+	"module_name":     {Column: "module_name", Type: String},
+	"module_duration": {Column: "module_duration", Type: Int32},
+	"exam_type":       {Column: "exam_type", Type: String},
+	"activated":       {Column: "activated", Type: Bool},
+	"created_at":      {Column: "created_at", Type: Time},
+}
+
+func TestParseCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		sql  string
+		args []any
+	}{
+		{
+			name: "comparison",
+			expr: "module_duration>=5",
+			sql:  "(module_duration >= $3)",
+			args: []any{int64(5)},
+		},
+		{
+			name: "not equal",
+			expr: "exam_type != written",
+			sql:  "(exam_type <> $3)",
+			args: []any{"written"},
+		},
+		{
+			name: "and binds tighter than or",
+			expr: `id=1 or id=2 and module_name="Algebra"`,
+			sql:  "((id = $3) OR ((id = $4) AND (module_name = $5)))",
+			args: []any{int64(1), int64(2), "Algebra"},
+		},
+		{
+			name: "parentheses and not",
+			expr: "not (id=1 or id=2) AND activated=true",
+			sql:  "((NOT ((id = $3) OR (id = $4))) AND (activated = $5))",
+			args: []any{int64(1), int64(2), true},
+		},
+		{
+			name: "in list",
+			expr: `exam_type in ("written", 'oral')`,
+			sql:  "(exam_type IN ($3, $4))",
+			args: []any{"written", "oral"},
+		},
+		{
+			name: "between",
+			expr: "module_duration between 5 and 15",
+			sql:  "(module_duration BETWEEN $3 AND $4)",
+			args: []any{int64(5), int64(15)},
+		},
+		{
+			name: "escaped quote",
+			expr: `module_name="say \"hi\""`,
+			sql:  "(module_name = $3)",
+			args: []any{`say "hi"`},
+		},
+		{
+			name: "date and timestamp",
+			expr: "created_at>2026-01-01 and created_at<2026-02-01T09:00:00+05:00",
+			sql:  "((created_at > $3) AND (created_at < $4))",
+			args: []any{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 4, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "largest int32",
+			expr: "module_duration<=2147483647",
+			sql:  "(module_duration <= $3)",
+			args: []any{int64(2147483647)},
+		},
+		{
+			name: "bigint column takes int64",
+			expr: "id>99999999999",
+			sql:  "(id > $3)",
+			args: []any{int64(99999999999)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.expr, testFields)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			sql, args := Compile(expr, 3)
+			if sql != tt.sql {
+				t.Errorf("sql = %q, want %q", sql, tt.sql)
+			}
+			if len(args) != len(tt.args) {
+				t.Fatalf("args = %v, want %v", args, tt.args)
+			}
+			for i := range args {
+				if got, ok := args[i].(time.Time); ok {
+					if !got.Equal(tt.args[i].(time.Time)) {
+						t.Errorf("args[%d] = %v, want %v", i, got, tt.args[i])
+					}
+					continue
+				}
+				if !reflect.DeepEqual(args[i], tt.args[i]) {
+					t.Errorf("args[%d] = %#v, want %#v", i, args[i], tt.args[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"unknown field", "id=1 and password=x", 10, `unknown field "password"`},
+		{"missing operator", "id 1", 4, `expected an operator after id, found "1"`},
+		{"missing value", "id=", 4, "expected a value, found end of expression"},
+		{"wrong type", "id=abc", 4, "id must be compared with an integer"},
+		{"int32 out of range", "module_duration>99999999999", 17, "99999999999 is out of range for module_duration"},
+		{"int64 out of range", "id=99999999999999999999", 4, "99999999999999999999 is out of range for id"},
+		{"bad bool operator", "activated<true", 10, "activated can only be compared with = or !="},
+		{"bad date", "created_at>2026-13-01", 12, "created_at must be compared with a date or timestamp"},
+		{"unterminated string", `module_name="Algebra`, 13, "unterminated string"},
+		{"unknown operator", "id==1", 3, `unknown operator "=="`},
+		{"unexpected character", "id=1 & id=2", 6, `unexpected character '&'`},
+		{"unclosed parenthesis", "(id=1", 6, `expected ")", found end of expression`},
+		{"trailing token", "id=1 id=2", 6, `unexpected "id"`},
+		{"between without and", "id between 1 or 2", 14, `expected and, found "or"`},
+		{"unclosed in list", "id in (1, 2", 12, `expected "," or ")", found end of expression`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expr, testFields)
+			var ferr *Error
+			if !errors.As(err, &ferr) {
+				t.Fatalf("Parse(%q) error = %v, want an *Error", tt.expr, err)
+			}
+			if ferr.Pos != tt.pos || ferr.Msg != tt.msg {
+				t.Errorf("got %q at %d, want %q at %d", ferr.Msg, ferr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	long := make([]byte, MaxLength+1)
+	for i := range long {
+		long[i] = ' '
+	}
+	deep := "id=1"
+	for i := 0; i <= maxDepth+1; i++ {
+		deep = "not " + deep
+	}
+	many := "id=0"
+	for i := 0; i < maxComparisons; i++ {
+		many += " or id=1"
+	}
+	tests := []struct {
+		name string
+		expr string
+		msg  string
+	}{
+		{"too long", string(long), "expression is longer than 2000 bytes"},
+		{"too deep", deep, "expression is nested more than 20 levels deep"},
+		{"too many comparisons", many, "expression has more than 50 comparisons"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expr, testFields)
+			var ferr *Error
+			if !errors.As(err, &ferr) || ferr.Msg != tt.msg {
+				t.Errorf("error = %v, want %q", err, tt.msg)
+			}
+		})
+	}
+}
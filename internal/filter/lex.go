@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenIdent is a field name, a keyword or an unquoted word value.
+	tokenIdent
+	// tokenString is a quoted string, with its quotes and escapes removed.
+	tokenString
+	// tokenLiteral is an unquoted number, date or timestamp.
+	tokenLiteral
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isLiteralPart reports whether c can continue an unquoted number, date or
+// timestamp such as -3, 4.5, 2026-01-01 or 2026-01-01T09:00:00+05:00.
+func isLiteralPart(c byte) bool {
+	return isIdentPart(c) || c == '-' || c == '+' || c == ':' || c == '.'
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: start + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: start + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: start + 1})
+			i++
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			i++
+			if i < len(s) && s[i] == '=' {
+				i++
+			}
+			op := s[start:i]
+			if op == "!" || op == "==" {
+				return nil, &Error{Pos: start + 1, Msg: fmt.Sprintf("unknown operator %q", op)}
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: start + 1})
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, &Error{Pos: start + 1, Msg: "unterminated string"}
+				}
+				if s[i] == c {
+					i++
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start + 1})
+		case isIdentStart(c):
+			for i < len(s) && isIdentPart(s[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[start:i], pos: start + 1})
+		case isDigit(c) || (c == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			i++
+			for i < len(s) && isLiteralPart(s[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: s[start:i], pos: start + 1})
+		default:
+			return nil, &Error{Pos: start + 1, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(s) + 1}), nil
+}
@@ -0,0 +1,318 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// documents. It works on values as produced by encoding/json decoding into an
+// interface value: map[string]any, []any, string, float64, bool and nil.
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch means the patch document itself is malformed.
+	ErrInvalidPatch = errors.New("invalid patch document")
+	// ErrTestFailed means a test operation did not match, so nothing was applied.
+	ErrTestFailed = errors.New("patch test failed")
+	// ErrPath means an operation refers to a location that does not exist or
+	// cannot hold a value.
+	ErrPath = errors.New("patch path error")
+)
+
+// Merge applies an RFC 7396 merge patch to doc and returns the result. doc itself
+// is not modified.
+func Merge(doc, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return deepCopy(patch)
+	}
+	docObj, ok := doc.(map[string]any)
+	if !ok {
+		docObj = map[string]any{}
+	}
+	result := make(map[string]any, len(docObj))
+	for key, value := range docObj {
+		result[key] = value
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		result[key] = Merge(result[key], value)
+	}
+	return deepCopy(result)
+}
+
+// Apply applies an RFC 6902 patch, a list of operations, to doc and returns the
+// result. The operations are applied in order and all or nothing: on error doc is
+// left as it was and the error wraps ErrInvalidPatch, ErrTestFailed or ErrPath.
+func Apply(doc, patch any) (any, error) {
+	ops, ok := patch.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: must be an array of operations", ErrInvalidPatch)
+	}
+	doc = deepCopy(doc)
+	for i, raw := range ops {
+		op, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: operation %d must be an object", ErrInvalidPatch, i)
+		}
+		var err error
+		doc, err = applyOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOp(doc any, op map[string]any) (any, error) {
+	name, _ := op["op"].(string)
+	path, err := stringMember(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "add", "replace", "test":
+		value, ok := op["value"]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires a value", ErrInvalidPatch, name)
+		}
+		switch name {
+		case "add":
+			return add(doc, path, deepCopy(value))
+		case "replace":
+			if path == "" {
+				return deepCopy(value), nil
+			}
+			doc, err = remove(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, deepCopy(value))
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, fmt.Errorf("%w: value at %q does not match", ErrTestFailed, path)
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, path)
+	case "move", "copy":
+		from, err := stringMember(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if name == "move" {
+			if path != from && strings.HasPrefix(path, from+"/") {
+				return nil, fmt.Errorf("%w: cannot move %q into one of its children", ErrPath, from)
+			}
+			doc, err = remove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return add(doc, path, deepCopy(value))
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, name)
+	}
+}
+
+func stringMember(op map[string]any, key string) (string, error) {
+	s, ok := op[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s must be a string", ErrInvalidPatch, key)
+	}
+	return s, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %q is not a JSON pointer", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token. With appendOK the "-" token, meaning one
+// past the end, is accepted.
+func arrayIndex(token string, length int, appendOK bool) (int, error) {
+	if token == "-" && appendOK {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPath, token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPath, token)
+	}
+	limit := length - 1
+	if appendOK {
+		limit = length
+	}
+	if i > limit {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrPath, i)
+	}
+	return i, nil
+}
+
+func get(doc any, pointer string) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q does not exist", ErrPath, pointer)
+			}
+			current = value
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q does not exist", ErrPath, pointer)
+		}
+	}
+	return current, nil
+}
+
+// update replaces the value at pointer with what fn returns for its parent
+// container and last token, rebuilding the containers along the way.
+func update(doc any, tokens []string, pointer string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q does not exist", ErrPath, pointer)
+		}
+		child, err := update(child, tokens[1:], pointer, fn)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = child
+		return node, nil
+	case []any:
+		i, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(node[i], tokens[1:], pointer, fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: %q does not exist", ErrPath, pointer)
+	}
+}
+
+func add(doc any, pointer string, value any) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return update(doc, tokens, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: cannot add to %q", ErrPath, pointer)
+		}
+	})
+}
+
+func remove(doc any, pointer string) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrPath)
+	}
+	return update(doc, tokens, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("%w: %q does not exist", ErrPath, pointer)
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q does not exist", ErrPath, pointer)
+		}
+	})
+}
+
+// equal compares two decoded JSON values. encoding/json decodes every number to
+// float64, so numbers compare by value.
+func equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func deepCopy(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(node))
+		for key, value := range node {
+			c[key] = deepCopy(value)
+		}
+		return c
+	case []any:
+		c := make([]any, len(node))
+		for i, value := range node {
+			c[i] = deepCopy(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,417 @@
+// Package timetable builds a clash-free weekly lecture timetable from a set of
+// modules, teachers, rooms and time slots.
+//
+// Every module needs a number of lectures per week. Each lecture has to be placed into
+// a (slot, room, teacher) triple so that no room or teacher is used twice in the same
+// slot, a teacher only teaches when available, the room is large enough for the
+// module and modules which share students never run at the same time. The search is
+// a backtracking search which always expands the lecture with the fewest remaining
+// options first. If it can't place everything within its step budget, a greedy pass
+// places as much as possible and reports why the rest could not be placed.
+//
+// The placements open to each module are worked out once up front. Every placement
+// then only updates the counts of the options it blocks, so choosing the next
+// lecture does not mean rebuilding the options of every lecture.
+package timetable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Slot is a weekly time slot, for example Monday 09:00-10:30. The slots of a problem
+// must not overlap; the solver treats different slots as different times.
+type Slot struct {
+	Day   int    `json:"day"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type Room struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+// Module is a module to be timetabled. Teachers lists who may teach it.
+type Module struct {
+	ID       int64
+	Name     string
+	Lectures int
+	Capacity int
+	Teachers []int64
+}
+
+// Teacher lists the slot indexes in which the teacher is available. A nil Available
+// slice means the teacher is available in every slot.
+type Teacher struct {
+	ID        int64
+	Available []int
+}
+
+type Problem struct {
+	Slots    []Slot
+	Rooms    []Room
+	Modules  []Module
+	Teachers []Teacher
+	// Clashes lists pairs of modules which must not be taught in the same slot,
+	// typically because they share students.
+	Clashes [][2]int64
+}
+
+type Assignment struct {
+	ModuleID  int64  `json:"module_id"`
+	Lecture   int    `json:"lecture"`
+	TeacherID int64  `json:"teacher_id"`
+	Room      string `json:"room"`
+	Slot      int    `json:"slot"`
+}
+
+// Unsatisfied describes a lecture which could not be placed and why.
+type Unsatisfied struct {
+	ModuleID int64  `json:"module_id"`
+	Lecture  int    `json:"lecture"`
+	Reason   string `json:"reason"`
+}
+
+type Solution struct {
+	Assignments []Assignment  `json:"assignments"`
+	Unsatisfied []Unsatisfied `json:"unsatisfied"`
+	Complete    bool          `json:"complete"`
+}
+
+// DefaultMaxSteps bounds the number of search nodes the backtracking search expands,
+// so a request can't run unbounded on an infeasible problem. The context passed to
+// Solve bounds it in time as well.
+const DefaultMaxSteps = 5_000
+
+type lecture struct {
+	module *Module
+	// m is the index of the module in the problem.
+	m     int
+	index int
+}
+
+type option struct {
+	slot    int
+	room    int
+	teacher int64
+}
+
+// ref names option o of module m.
+type ref struct {
+	m, o int
+}
+
+type teacherSlot struct {
+	teacher int64
+	slot    int
+}
+
+type solver struct {
+	ctx       context.Context
+	problem   Problem
+	lectures  []lecture
+	available map[int64]map[int]bool
+	// clashes lists, by module index, the indexes of the modules it clashes with.
+	clashes [][]int
+
+	// options holds every placement each module's room, teacher and availability
+	// constraints allow. blocked counts the placements currently ruling each of
+	// them out, and open the options of each module with a count of zero.
+	options [][]option
+	blocked [][]int
+	open    []int
+	// The indexes list the options a placement in a room, by a teacher or of a
+	// module at a given slot rules out.
+	byRoomSlot    map[[2]int][]ref
+	byTeacherSlot map[teacherSlot][]ref
+	byModuleSlot  map[[2]int][]ref
+	moduleDays    []map[int]int
+
+	assigned []*option
+	steps    int
+	maxSteps int
+}
+
+func newSolver(ctx context.Context, p Problem, maxSteps int) *solver {
+	s := &solver{
+		ctx:           ctx,
+		problem:       p,
+		available:     make(map[int64]map[int]bool),
+		clashes:       make([][]int, len(p.Modules)),
+		options:       make([][]option, len(p.Modules)),
+		blocked:       make([][]int, len(p.Modules)),
+		open:          make([]int, len(p.Modules)),
+		byRoomSlot:    make(map[[2]int][]ref),
+		byTeacherSlot: make(map[teacherSlot][]ref),
+		byModuleSlot:  make(map[[2]int][]ref),
+		moduleDays:    make([]map[int]int, len(p.Modules)),
+		maxSteps:      maxSteps,
+	}
+	for _, teacher := range p.Teachers {
+		if teacher.Available == nil {
+			continue
+		}
+		slots := make(map[int]bool)
+		for _, slot := range teacher.Available {
+			slots[slot] = true
+		}
+		s.available[teacher.ID] = slots
+	}
+	index := make(map[int64]int, len(p.Modules))
+	for m, module := range p.Modules {
+		index[module.ID] = m
+	}
+	for _, clash := range p.Clashes {
+		a, okA := index[clash[0]]
+		b, okB := index[clash[1]]
+		if okA && okB && a != b {
+			s.clashes[a] = append(s.clashes[a], b)
+			s.clashes[b] = append(s.clashes[b], a)
+		}
+	}
+	for m := range s.problem.Modules {
+		module := &s.problem.Modules[m]
+		s.moduleDays[m] = make(map[int]int)
+		var teachers []int64
+		seen := make(map[int64]bool)
+		for _, teacher := range module.Teachers {
+			if !seen[teacher] {
+				seen[teacher] = true
+				teachers = append(teachers, teacher)
+			}
+		}
+		for slot := range p.Slots {
+			for _, teacher := range teachers {
+				if !s.teacherAvailable(teacher, slot) {
+					continue
+				}
+				for room, r := range p.Rooms {
+					if r.Capacity < module.Capacity {
+						continue
+					}
+					o := len(s.options[m])
+					s.options[m] = append(s.options[m], option{slot: slot, room: room, teacher: teacher})
+					s.byRoomSlot[[2]int{room, slot}] = append(s.byRoomSlot[[2]int{room, slot}], ref{m, o})
+					s.byTeacherSlot[teacherSlot{teacher, slot}] = append(s.byTeacherSlot[teacherSlot{teacher, slot}], ref{m, o})
+					s.byModuleSlot[[2]int{m, slot}] = append(s.byModuleSlot[[2]int{m, slot}], ref{m, o})
+				}
+			}
+		}
+		s.blocked[m] = make([]int, len(s.options[m]))
+		s.open[m] = len(s.options[m])
+		for n := 0; n < module.Lectures; n++ {
+			s.lectures = append(s.lectures, lecture{module: module, m: m, index: n + 1})
+		}
+	}
+	s.assigned = make([]*option, len(s.lectures))
+	return s
+}
+
+func (s *solver) teacherAvailable(teacher int64, slot int) bool {
+	slots, ok := s.available[teacher]
+	return !ok || slots[slot]
+}
+
+// block adds delta to the count of placements ruling out each of refs, keeping the
+// number of open options of their modules up to date.
+func (s *solver) block(refs []ref, delta int) {
+	for _, r := range refs {
+		before := s.blocked[r.m][r.o]
+		after := before + delta
+		s.blocked[r.m][r.o] = after
+		switch {
+		case before == 0 && after > 0:
+			s.open[r.m]--
+		case before > 0 && after == 0:
+			s.open[r.m]++
+		}
+	}
+}
+
+// update applies the effect of lecture i being placed at o (delta 1) or removed from
+// it (delta -1): the room and teacher are taken at that slot, and neither the
+// module nor any module clashing with it can use the slot again.
+func (s *solver) update(i int, o option, delta int) {
+	m := s.lectures[i].m
+	s.block(s.byRoomSlot[[2]int{o.room, o.slot}], delta)
+	s.block(s.byTeacherSlot[teacherSlot{o.teacher, o.slot}], delta)
+	s.block(s.byModuleSlot[[2]int{m, o.slot}], delta)
+	for _, other := range s.clashes[m] {
+		s.block(s.byModuleSlot[[2]int{other, o.slot}], delta)
+	}
+	s.moduleDays[m][s.problem.Slots[o.slot].Day] += delta
+}
+
+func (s *solver) place(i int, o option) {
+	s.assigned[i] = &o
+	s.update(i, o, 1)
+}
+
+func (s *solver) unplace(i int) {
+	o := s.assigned[i]
+	s.assigned[i] = nil
+	s.update(i, *o, -1)
+}
+
+// candidates lists every placement still open to lecture i, preferring days on
+// which the module has no lecture yet so a module's lectures are spread over the
+// week.
+func (s *solver) candidates(i int) []option {
+	m := s.lectures[i].m
+	opts := make([]option, 0, s.open[m])
+	for o, count := range s.blocked[m] {
+		if count == 0 {
+			opts = append(opts, s.options[m][o])
+		}
+	}
+	days := s.moduleDays[m]
+	sort.SliceStable(opts, func(i, j int) bool {
+		return days[s.problem.Slots[opts[i].slot].Day] < days[s.problem.Slots[opts[j].slot].Day]
+	})
+	return opts
+}
+
+// next returns the unplaced lecture, other than those in skipped, with the fewest
+// open options, or -1 once there is none.
+func (s *solver) next(skipped map[int]bool) int {
+	best := -1
+	for i, l := range s.lectures {
+		if s.assigned[i] != nil || skipped[i] {
+			continue
+		}
+		if best == -1 || s.open[l.m] < s.open[s.lectures[best].m] {
+			best = i
+			if s.open[l.m] == 0 {
+				break
+			}
+		}
+	}
+	return best
+}
+
+// stopped reports whether the search has used up its step budget or its time.
+func (s *solver) stopped() bool {
+	return s.steps > s.maxSteps || s.ctx.Err() != nil
+}
+
+func (s *solver) search() bool {
+	s.steps++
+	if s.stopped() {
+		return false
+	}
+	i := s.next(nil)
+	if i == -1 {
+		return true
+	}
+	for _, o := range s.candidates(i) {
+		s.place(i, o)
+		if s.search() {
+			return true
+		}
+		s.unplace(i)
+		if s.stopped() {
+			return false
+		}
+	}
+	return false
+}
+
+// greedy places lectures one at a time, most constrained first, without ever undoing
+// a placement. Lectures with no options left are reported as unsatisfied.
+func (s *solver) greedy() []Unsatisfied {
+	unsatisfied := []Unsatisfied{}
+	skipped := make(map[int]bool)
+	for {
+		i := s.next(skipped)
+		if i == -1 {
+			return unsatisfied
+		}
+		l := s.lectures[i]
+		if s.open[l.m] == 0 {
+			skipped[i] = true
+			unsatisfied = append(unsatisfied, Unsatisfied{ModuleID: l.module.ID, Lecture: l.index, Reason: s.reason(l)})
+			continue
+		}
+		s.place(i, s.candidates(i)[0])
+	}
+}
+
+// reason works out which constraint stops a lecture from being placed, checking the
+// static constraints first and the clashes with other placements last.
+func (s *solver) reason(l lecture) string {
+	if len(l.module.Teachers) == 0 {
+		return "no teacher is assigned to the module"
+	}
+	bigEnough := false
+	for _, room := range s.problem.Rooms {
+		if room.Capacity >= l.module.Capacity {
+			bigEnough = true
+		}
+	}
+	if !bigEnough {
+		return fmt.Sprintf("no room has capacity for %d students", l.module.Capacity)
+	}
+	teacherFree := false
+	for slot := range s.problem.Slots {
+		for _, teacher := range l.module.Teachers {
+			if s.teacherAvailable(teacher, slot) {
+				teacherFree = true
+			}
+		}
+	}
+	if !teacherFree {
+		return "no teacher of the module is available in any slot"
+	}
+	if l.module.Lectures > len(s.problem.Slots) {
+		return fmt.Sprintf("the module needs %d lectures but there are only %d slots", l.module.Lectures, len(s.problem.Slots))
+	}
+	return "every slot in which a teacher is available is taken by a room, teacher or student clash"
+}
+
+func (s *solver) solution() Solution {
+	solution := Solution{Assignments: []Assignment{}, Unsatisfied: []Unsatisfied{}}
+	for i, o := range s.assigned {
+		if o == nil {
+			continue
+		}
+		l := s.lectures[i]
+		solution.Assignments = append(solution.Assignments, Assignment{
+			ModuleID:  l.module.ID,
+			Lecture:   l.index,
+			TeacherID: o.teacher,
+			Room:      s.problem.Rooms[o.room].Name,
+			Slot:      o.slot,
+		})
+	}
+	sort.Slice(solution.Assignments, func(i, j int) bool {
+		a, b := solution.Assignments[i], solution.Assignments[j]
+		if a.Slot != b.Slot {
+			return a.Slot < b.Slot
+		}
+		return a.Room < b.Room
+	})
+	return solution
+}
+
+// Solve builds a timetable for the problem. maxSteps bounds the backtracking search;
+// pass zero to use DefaultMaxSteps. When ctx is done the search stops early too, and
+// the greedy pass, which is cheap, builds the timetable instead.
+func Solve(ctx context.Context, p Problem, maxSteps int) Solution {
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	s := newSolver(ctx, p, maxSteps)
+	if s.search() {
+		solution := s.solution()
+		solution.Complete = true
+		return solution
+	}
+	s = newSolver(ctx, p, maxSteps)
+	unsatisfied := s.greedy()
+	solution := s.solution()
+	solution.Unsatisfied = unsatisfied
+	solution.Complete = len(unsatisfied) == 0
+	return solution
+}
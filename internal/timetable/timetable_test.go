@@ -0,0 +1,255 @@
+package timetable
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// slots returns n one-hour slots spread over days 1 to 5.
+func slots(n int) []Slot {
+	s := make([]Slot, n)
+	for i := range s {
+		s[i] = Slot{Day: i%5 + 1, Start: fmt.Sprintf("%02d:00", 9+i/5), End: fmt.Sprintf("%02d:00", 10+i/5)}
+	}
+	return s
+}
+
+// checkClashFree fails the test if the solution breaks any hard constraint of p.
+func checkClashFree(t *testing.T, p Problem, solution Solution) {
+	t.Helper()
+	modules := make(map[int64]Module)
+	for _, m := range p.Modules {
+		modules[m.ID] = m
+	}
+	available := make(map[int64]map[int]bool)
+	for _, teacher := range p.Teachers {
+		if teacher.Available == nil {
+			continue
+		}
+		available[teacher.ID] = make(map[int]bool)
+		for _, slot := range teacher.Available {
+			available[teacher.ID][slot] = true
+		}
+	}
+	rooms := make(map[string]Room)
+	for _, room := range p.Rooms {
+		rooms[room.Name] = room
+	}
+	roomBusy := make(map[string]bool)
+	teacherBusy := make(map[string]bool)
+	moduleAt := make(map[int]map[int64]bool)
+	for _, a := range solution.Assignments {
+		m := modules[a.ModuleID]
+		if key := fmt.Sprintf("%s/%d", a.Room, a.Slot); roomBusy[key] {
+			t.Errorf("room %s is double-booked in slot %d", a.Room, a.Slot)
+		} else {
+			roomBusy[key] = true
+		}
+		if key := fmt.Sprintf("%d/%d", a.TeacherID, a.Slot); teacherBusy[key] {
+			t.Errorf("teacher %d is double-booked in slot %d", a.TeacherID, a.Slot)
+		} else {
+			teacherBusy[key] = true
+		}
+		if slots, ok := available[a.TeacherID]; ok && !slots[a.Slot] {
+			t.Errorf("teacher %d is not available in slot %d", a.TeacherID, a.Slot)
+		}
+		if rooms[a.Room].Capacity < m.Capacity {
+			t.Errorf("room %s is too small for module %d", a.Room, a.ModuleID)
+		}
+		teaches := false
+		for _, teacher := range m.Teachers {
+			teaches = teaches || teacher == a.TeacherID
+		}
+		if !teaches {
+			t.Errorf("teacher %d does not teach module %d", a.TeacherID, a.ModuleID)
+		}
+		if moduleAt[a.Slot] == nil {
+			moduleAt[a.Slot] = make(map[int64]bool)
+		}
+		if moduleAt[a.Slot][a.ModuleID] {
+			t.Errorf("module %d has two lectures in slot %d", a.ModuleID, a.Slot)
+		}
+		moduleAt[a.Slot][a.ModuleID] = true
+	}
+	for _, clash := range p.Clashes {
+		for slot, at := range moduleAt {
+			if at[clash[0]] && at[clash[1]] {
+				t.Errorf("clashing modules %d and %d both run in slot %d", clash[0], clash[1], slot)
+			}
+		}
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		problem     Problem
+		complete    bool
+		assignments int
+		reason      string
+	}{
+		{
+			name: "single lecture",
+			problem: Problem{
+				Slots:   slots(1),
+				Rooms:   []Room{{Name: "A", Capacity: 30}},
+				Modules: []Module{{ID: 1, Lectures: 1, Capacity: 20, Teachers: []int64{10}}},
+			},
+			complete:    true,
+			assignments: 1,
+		},
+		{
+			name: "shared teacher needs separate slots",
+			problem: Problem{
+				Slots: slots(2),
+				Rooms: []Room{{Name: "A", Capacity: 30}, {Name: "B", Capacity: 30}},
+				Modules: []Module{
+					{ID: 1, Lectures: 1, Capacity: 20, Teachers: []int64{10}},
+					{ID: 2, Lectures: 1, Capacity: 20, Teachers: []int64{10}},
+				},
+			},
+			complete:    true,
+			assignments: 2,
+		},
+		{
+			name: "clashing modules need separate slots",
+			problem: Problem{
+				Slots: slots(2),
+				Rooms: []Room{{Name: "A", Capacity: 30}, {Name: "B", Capacity: 30}},
+				Modules: []Module{
+					{ID: 1, Lectures: 1, Capacity: 20, Teachers: []int64{10}},
+					{ID: 2, Lectures: 1, Capacity: 20, Teachers: []int64{11}},
+				},
+				Clashes: [][2]int64{{1, 2}},
+			},
+			complete:    true,
+			assignments: 2,
+		},
+		{
+			name: "most constrained module is placed first",
+			problem: Problem{
+				Slots: slots(3),
+				Rooms: []Room{{Name: "A", Capacity: 30}},
+				Modules: []Module{
+					{ID: 1, Lectures: 2, Capacity: 20, Teachers: []int64{10}},
+					{ID: 2, Lectures: 1, Capacity: 20, Teachers: []int64{11}},
+				},
+				Teachers: []Teacher{{ID: 11, Available: []int{0}}},
+			},
+			complete:    true,
+			assignments: 3,
+		},
+		{
+			name: "no room is big enough",
+			problem: Problem{
+				Slots:   slots(2),
+				Rooms:   []Room{{Name: "A", Capacity: 10}},
+				Modules: []Module{{ID: 1, Lectures: 1, Capacity: 20, Teachers: []int64{10}}},
+			},
+			reason: "no room has capacity for 20 students",
+		},
+		{
+			name: "no teacher",
+			problem: Problem{
+				Slots:   slots(2),
+				Rooms:   []Room{{Name: "A", Capacity: 30}},
+				Modules: []Module{{ID: 1, Lectures: 1, Capacity: 20}},
+			},
+			reason: "no teacher is assigned to the module",
+		},
+		{
+			name: "teacher never available",
+			problem: Problem{
+				Slots:    slots(2),
+				Rooms:    []Room{{Name: "A", Capacity: 30}},
+				Modules:  []Module{{ID: 1, Lectures: 1, Capacity: 20, Teachers: []int64{10}}},
+				Teachers: []Teacher{{ID: 10, Available: []int{}}},
+			},
+			reason: "no teacher of the module is available in any slot",
+		},
+		{
+			name: "more lectures than slots",
+			problem: Problem{
+				Slots:   slots(2),
+				Rooms:   []Room{{Name: "A", Capacity: 30}},
+				Modules: []Module{{ID: 1, Lectures: 3, Capacity: 20, Teachers: []int64{10}}},
+			},
+			assignments: 2,
+			reason:      "the module needs 3 lectures but there are only 2 slots",
+		},
+		{
+			name: "one room for too many lectures",
+			problem: Problem{
+				Slots: slots(2),
+				Rooms: []Room{{Name: "A", Capacity: 30}},
+				Modules: []Module{
+					{ID: 1, Lectures: 2, Capacity: 20, Teachers: []int64{10}},
+					{ID: 2, Lectures: 1, Capacity: 20, Teachers: []int64{11}},
+				},
+			},
+			assignments: 2,
+			reason:      "every slot in which a teacher is available is taken by a room, teacher or student clash",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution := Solve(context.Background(), tt.problem, 0)
+			if solution.Complete != tt.complete {
+				t.Fatalf("complete = %v, want %v (unsatisfied %v)", solution.Complete, tt.complete, solution.Unsatisfied)
+			}
+			if len(solution.Assignments) != tt.assignments {
+				t.Errorf("got %d assignments, want %d", len(solution.Assignments), tt.assignments)
+			}
+			if tt.reason != "" {
+				if len(solution.Unsatisfied) == 0 {
+					t.Fatalf("no unsatisfied lectures, want reason %q", tt.reason)
+				}
+				if got := solution.Unsatisfied[0].Reason; got != tt.reason {
+					t.Errorf("reason = %q, want %q", got, tt.reason)
+				}
+			}
+			checkClashFree(t, tt.problem, solution)
+		})
+	}
+}
+
+// TestSolveLarge checks a problem that needs most of the rooms in most slots still
+// comes out clash-free and complete.
+func TestSolveLarge(t *testing.T) {
+	p := Problem{Slots: slots(20)}
+	for i := 0; i < 5; i++ {
+		p.Rooms = append(p.Rooms, Room{Name: fmt.Sprintf("R%d", i), Capacity: 30 + 10*i})
+	}
+	for i := 0; i < 30; i++ {
+		p.Modules = append(p.Modules, Module{ID: int64(i + 1), Lectures: 2, Capacity: 20 + i, Teachers: []int64{int64(100 + i%10)}})
+		if i > 0 && i%3 == 0 {
+			p.Clashes = append(p.Clashes, [2]int64{int64(i), int64(i + 1)})
+		}
+	}
+	solution := Solve(context.Background(), p, 0)
+	if !solution.Complete {
+		t.Fatalf("not complete: %v", solution.Unsatisfied)
+	}
+	checkClashFree(t, p, solution)
+}
+
+// TestSolveDeadline checks an expired context stops the search but still yields a
+// clash-free timetable from the greedy pass.
+func TestSolveDeadline(t *testing.T) {
+	p := Problem{Slots: slots(4), Rooms: []Room{{Name: "A", Capacity: 30}}}
+	for i := 0; i < 6; i++ {
+		p.Modules = append(p.Modules, Module{ID: int64(i + 1), Lectures: 1, Capacity: 20, Teachers: []int64{10}})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	solution := Solve(ctx, p, 0)
+	if solution.Complete {
+		t.Fatal("six lectures cannot fit four slots")
+	}
+	if len(solution.Assignments) != 4 || len(solution.Unsatisfied) != 2 {
+		t.Errorf("got %d assignments and %d unsatisfied, want 4 and 2", len(solution.Assignments), len(solution.Unsatisfied))
+	}
+	checkClashFree(t, p, solution)
+}
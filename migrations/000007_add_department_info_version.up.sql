@@ -0,0 +1 @@
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS module_id BIGINT REFERENCES module_info(id);
+UPDATE department_info SET module_id = dm.module_id
+FROM (SELECT department_id, MIN(module_id) AS module_id FROM department_modules GROUP BY department_id) dm
+WHERE department_info.id = dm.department_id;
+DROP TABLE IF EXISTS department_modules;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS department_modules (
+    department_id BIGINT NOT NULL REFERENCES department_info ON DELETE CASCADE,
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    PRIMARY KEY (department_id, module_id)
+);
+INSERT INTO department_modules (department_id, module_id)
+SELECT id, module_id FROM department_info WHERE module_id IS NOT NULL;
+ALTER TABLE department_info DROP COLUMN IF EXISTS module_id;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS program_modules;
+DROP TABLE IF EXISTS programs;
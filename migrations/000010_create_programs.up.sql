@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS programs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    program_name VARCHAR(255) NOT NULL,
+    semester_count INTEGER NOT NULL,
+    max_semester_duration INTEGER NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT semester_count_check CHECK (semester_count >= 1 AND semester_count <= 16),
+    CONSTRAINT max_semester_duration_check CHECK (max_semester_duration > 0)
+);
+CREATE TABLE IF NOT EXISTS program_modules (
+    program_id BIGINT NOT NULL REFERENCES programs ON DELETE CASCADE,
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    semester INTEGER NOT NULL CHECK (semester >= 1),
+    PRIMARY KEY (program_id, module_id)
+);
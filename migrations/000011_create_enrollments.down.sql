@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS enrollments;
+ALTER TABLE module_info DROP CONSTRAINT IF EXISTS capacity_check;
+ALTER TABLE module_info DROP COLUMN IF EXISTS capacity;
@@ -0,0 +1,12 @@
+ALTER TABLE module_info ADD COLUMN IF NOT EXISTS capacity INTEGER NOT NULL DEFAULT 30;
+ALTER TABLE module_info ADD CONSTRAINT capacity_check CHECK (capacity > 0);
+CREATE TABLE IF NOT EXISTS enrollments (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES user_info ON DELETE CASCADE,
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    CONSTRAINT enrollments_user_module_key UNIQUE (user_id, module_id),
+    CONSTRAINT enrollments_status_check CHECK (status IN ('enrolled', 'waitlisted'))
+);
+CREATE INDEX IF NOT EXISTS enrollments_module_id_status_idx ON enrollments (module_id, status, created_at, id);
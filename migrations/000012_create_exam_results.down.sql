@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code IN ('results:read', 'results:write');
+DROP TABLE IF EXISTS exam_results;
+DROP TABLE IF EXISTS grading_scales;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS grading_scales (
+    exam_type VARCHAR(255) NOT NULL DEFAULT '',
+    letter VARCHAR(5) NOT NULL,
+    min_score NUMERIC(5,2) NOT NULL,
+    gpa_points NUMERIC(3,2) NOT NULL,
+    PRIMARY KEY (exam_type, letter),
+    CONSTRAINT min_score_check CHECK (min_score >= 0 AND min_score <= 100),
+    CONSTRAINT gpa_points_check CHECK (gpa_points >= 0 AND gpa_points <= 4)
+);
+-- The scale with an empty exam_type is the default used for every exam type that
+-- doesn't have its own scale.
+INSERT INTO grading_scales (exam_type, letter, min_score, gpa_points)
+VALUES
+    ('', 'A', 95, 4.00),
+    ('', 'A-', 90, 3.67),
+    ('', 'B+', 85, 3.33),
+    ('', 'B', 80, 3.00),
+    ('', 'B-', 75, 2.67),
+    ('', 'C+', 70, 2.33),
+    ('', 'C', 65, 2.00),
+    ('', 'C-', 60, 1.67),
+    ('', 'D+', 55, 1.33),
+    ('', 'D', 50, 1.00),
+    ('', 'F', 0, 0.00);
+CREATE TABLE IF NOT EXISTS exam_results (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES user_info ON DELETE CASCADE,
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    score NUMERIC(5,2) NOT NULL,
+    recorded_by BIGINT REFERENCES user_info ON DELETE SET NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT exam_results_user_module_key UNIQUE (user_id, module_id),
+    CONSTRAINT score_check CHECK (score >= 0 AND score <= 100)
+);
+CREATE INDEX IF NOT EXISTS exam_results_module_id_idx ON exam_results (module_id);
+INSERT INTO permissions (code)
+VALUES
+    ('results:read'),
+    ('results:write');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS timetable_entries;
+DROP TABLE IF EXISTS timetables;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS timetables (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    timetable_name VARCHAR(255) NOT NULL,
+    version INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'draft',
+    published_at TIMESTAMP(0) WITH TIME ZONE,
+    complete BOOLEAN NOT NULL,
+    unsatisfied JSONB NOT NULL DEFAULT '[]',
+    CONSTRAINT timetables_name_version_key UNIQUE (timetable_name, version),
+    CONSTRAINT timetables_status_check CHECK (status IN ('draft', 'published', 'archived'))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS timetables_published_idx ON timetables (timetable_name) WHERE status = 'published';
+CREATE TABLE IF NOT EXISTS timetable_entries (
+    id BIGSERIAL PRIMARY KEY,
+    timetable_id BIGINT NOT NULL REFERENCES timetables ON DELETE CASCADE,
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    lecture INTEGER NOT NULL,
+    teacher_id BIGINT REFERENCES user_info ON DELETE SET NULL,
+    room VARCHAR(255) NOT NULL,
+    day INTEGER NOT NULL CHECK (day >= 1 AND day <= 7),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS timetable_entries_timetable_id_idx ON timetable_entries (timetable_id);
@@ -0,0 +1,7 @@
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS staff_quantity INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS department_director VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE department_info SET staff_quantity = (SELECT count(*) FROM department_staff WHERE department_staff.department_id = department_info.id);
+UPDATE department_info SET department_director = user_info.fname || ' ' || user_info.sname
+FROM user_info WHERE user_info.id = department_info.director_id;
+ALTER TABLE department_info DROP COLUMN IF EXISTS director_id;
+DROP TABLE IF EXISTS department_staff;
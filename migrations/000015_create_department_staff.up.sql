@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS department_staff (
+    department_id BIGINT NOT NULL REFERENCES department_info ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES user_info ON DELETE CASCADE,
+    staff_role VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (department_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS department_staff_user_id_idx ON department_staff (user_id);
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS director_id BIGINT REFERENCES user_info ON DELETE SET NULL;
+-- Keep the director where the free-text name matches exactly one user.
+UPDATE department_info SET director_id = matched.user_id
+FROM (
+    SELECT department_info.id AS department_id, MIN(user_info.id) AS user_id
+    FROM department_info
+    INNER JOIN user_info ON lower(user_info.fname || ' ' || user_info.sname) = lower(trim(department_info.department_director))
+    GROUP BY department_info.id
+    HAVING count(*) = 1
+) matched
+WHERE department_info.id = matched.department_id;
+ALTER TABLE department_info DROP COLUMN IF EXISTS department_director;
+ALTER TABLE department_info DROP COLUMN IF EXISTS staff_quantity;
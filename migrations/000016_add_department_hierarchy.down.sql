@@ -0,0 +1,4 @@
+ALTER TABLE department_info DROP CONSTRAINT IF EXISTS parent_id_check;
+ALTER TABLE department_info DROP CONSTRAINT IF EXISTS unit_type_check;
+ALTER TABLE department_info DROP COLUMN IF EXISTS unit_type;
+ALTER TABLE department_info DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,5 @@
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES department_info ON DELETE RESTRICT;
+ALTER TABLE department_info ADD COLUMN IF NOT EXISTS unit_type VARCHAR(32) NOT NULL DEFAULT 'department';
+ALTER TABLE department_info ADD CONSTRAINT unit_type_check CHECK (unit_type IN ('faculty', 'department', 'unit'));
+ALTER TABLE department_info ADD CONSTRAINT parent_id_check CHECK (parent_id <> id);
+CREATE INDEX IF NOT EXISTS department_info_parent_id_idx ON department_info (parent_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS user_info_deleted_at_idx;
+DROP INDEX IF EXISTS module_info_deleted_at_idx;
+ALTER TABLE user_info DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE module_info DROP COLUMN IF EXISTS deleted_at;
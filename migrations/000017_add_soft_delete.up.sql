@@ -0,0 +1,4 @@
+ALTER TABLE module_info ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP(0) WITH TIME ZONE;
+ALTER TABLE user_info ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP(0) WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS module_info_deleted_at_idx ON module_info (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS user_info_deleted_at_idx ON user_info (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS module_info_history (
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    module_name VARCHAR(255) NOT NULL,
+    module_duration INTEGER NOT NULL,
+    exam_type VARCHAR(255) NOT NULL,
+    capacity INTEGER NOT NULL,
+    changed_by BIGINT REFERENCES user_info ON DELETE SET NULL,
+    changed_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (module_id, version)
+);
+-- Existing modules start their history at whatever version they are on now.
+INSERT INTO module_info_history (module_id, version, module_name, module_duration, exam_type, capacity, changed_at)
+SELECT id, version, module_name, module_duration, exam_type, capacity, updated_at
+FROM module_info
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE code = 'examtypes:write';
+DROP INDEX IF EXISTS module_info_exam_type_idx;
+ALTER TABLE module_info DROP CONSTRAINT IF EXISTS module_info_exam_type_fkey;
+ALTER TABLE module_info ALTER COLUMN exam_type TYPE VARCHAR(255);
+-- Modules keep their codes; the original free text is not recoverable.
+DROP TABLE IF EXISTS exam_types;
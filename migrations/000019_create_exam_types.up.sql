@@ -0,0 +1,69 @@
+CREATE TABLE IF NOT EXISTS exam_types (
+    code VARCHAR(32) PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    display_name VARCHAR(255) NOT NULL,
+    weighting NUMERIC(5,2) NOT NULL DEFAULT 100,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT code_check CHECK (code ~ '^[a-z0-9_]+$'),
+    CONSTRAINT weighting_check CHECK (weighting >= 0 AND weighting <= 100)
+);
+INSERT INTO exam_types (code, display_name, weighting)
+VALUES
+    ('written', 'Written exam', 100),
+    ('oral', 'Oral exam', 100),
+    ('project', 'Project', 100),
+    ('coursework', 'Coursework', 100),
+    ('practical', 'Practical exam', 100);
+
+-- Map every free-text exam type in use to a code. Common spellings go to the seeded
+-- codes; anything else becomes its own catalogue entry so no information is lost and
+-- an admin can merge it later.
+CREATE TEMPORARY TABLE exam_type_map AS
+SELECT free_text, CASE
+    WHEN lower(trim(free_text)) IN ('exam', 'written', 'written exam', 'final', 'final exam', 'test', 'written test') THEN 'written'
+    WHEN lower(trim(free_text)) LIKE 'oral%' OR lower(trim(free_text)) = 'viva' THEN 'oral'
+    WHEN lower(trim(free_text)) LIKE '%project%' THEN 'project'
+    WHEN lower(trim(free_text)) IN ('coursework', 'course work', 'assignment', 'assignments', 'essay') THEN 'coursework'
+    WHEN lower(trim(free_text)) LIKE '%practical%' OR lower(trim(free_text)) LIKE 'lab%' THEN 'practical'
+    ELSE COALESCE(NULLIF(left(trim(BOTH '_' FROM lower(regexp_replace(trim(free_text), '[^A-Za-z0-9]+', '_', 'g'))), 32), ''), 'other')
+END AS code
+FROM (
+    SELECT exam_type AS free_text FROM module_info
+    UNION SELECT exam_type FROM module_info_history
+    UNION SELECT exam_type FROM grading_scales WHERE exam_type <> ''
+) AS in_use;
+
+INSERT INTO exam_types (code, display_name)
+SELECT code, initcap(min(trim(free_text))) FROM exam_type_map GROUP BY code
+ON CONFLICT (code) DO NOTHING;
+
+UPDATE module_info SET exam_type = exam_type_map.code
+FROM exam_type_map WHERE module_info.exam_type = exam_type_map.free_text;
+UPDATE module_info_history SET exam_type = exam_type_map.code
+FROM exam_type_map WHERE module_info_history.exam_type = exam_type_map.free_text;
+
+-- Several free-text values can collapse onto one code, so keep a single scale per
+-- code, preferring one that was already stored under the code itself.
+CREATE TEMPORARY TABLE exam_type_scale_source AS
+SELECT DISTINCT ON (exam_type_map.code) exam_type_map.code, exam_type_map.free_text
+FROM exam_type_map
+INNER JOIN grading_scales ON grading_scales.exam_type = exam_type_map.free_text
+ORDER BY exam_type_map.code, (exam_type_map.free_text = exam_type_map.code) DESC, exam_type_map.free_text;
+
+DELETE FROM grading_scales
+WHERE exam_type <> ''
+AND exam_type NOT IN (SELECT free_text FROM exam_type_scale_source);
+UPDATE grading_scales SET exam_type = exam_type_scale_source.code
+FROM exam_type_scale_source WHERE grading_scales.exam_type = exam_type_scale_source.free_text;
+
+DROP TABLE exam_type_scale_source;
+DROP TABLE exam_type_map;
+
+ALTER TABLE module_info ALTER COLUMN exam_type TYPE VARCHAR(32);
+ALTER TABLE module_info ADD CONSTRAINT module_info_exam_type_fkey
+    FOREIGN KEY (exam_type) REFERENCES exam_types (code) ON UPDATE CASCADE ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS module_info_exam_type_idx ON module_info (exam_type);
+
+INSERT INTO permissions (code)
+VALUES
+    ('examtypes:write');
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS module_attachments (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    module_id BIGINT NOT NULL REFERENCES module_info ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL DEFAULT 'other',
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    storage_key VARCHAR(128) NOT NULL UNIQUE,
+    uploaded_by BIGINT REFERENCES user_info ON DELETE SET NULL,
+    CONSTRAINT kind_check CHECK (kind IN ('syllabus', 'reading_list', 'exam_spec', 'other')),
+    CONSTRAINT size_bytes_check CHECK (size_bytes >= 0)
+);
+CREATE INDEX IF NOT EXISTS module_attachments_module_id_idx ON module_attachments (module_id);
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE code = 'users:admin';
+DROP INDEX IF EXISTS user_info_full_name_search_idx;
+DROP INDEX IF EXISTS user_info_sname_search_idx;
+DROP INDEX IF EXISTS user_info_fname_search_idx;
+DROP INDEX IF EXISTS department_info_department_name_search_idx;
+DROP INDEX IF EXISTS module_info_module_name_search_idx;
@@ -0,0 +1,11 @@
+-- Expression indexes for the to_tsvector('simple', ...) searches. Each expression
+-- has to match the one in the queries exactly for the planner to use it.
+CREATE INDEX IF NOT EXISTS module_info_module_name_search_idx ON module_info USING GIN (to_tsvector('simple', module_name));
+CREATE INDEX IF NOT EXISTS department_info_department_name_search_idx ON department_info USING GIN (to_tsvector('simple', department_name));
+CREATE INDEX IF NOT EXISTS user_info_fname_search_idx ON user_info USING GIN (to_tsvector('simple', fname));
+CREATE INDEX IF NOT EXISTS user_info_sname_search_idx ON user_info USING GIN (to_tsvector('simple', sname));
+CREATE INDEX IF NOT EXISTS user_info_full_name_search_idx ON user_info USING GIN (to_tsvector('simple', fname || ' ' || sname));
+-- Lets a user see other users' email addresses in search results.
+INSERT INTO permissions (code)
+VALUES
+    ('users:admin');
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS user_info_full_name_prefix_idx;
+DROP INDEX IF EXISTS user_info_full_name_trgm_idx;
+DROP INDEX IF EXISTS module_info_module_name_prefix_idx;
+DROP INDEX IF EXISTS module_info_module_name_trgm_idx;
+-- The extension is left installed; other objects may depend on it.
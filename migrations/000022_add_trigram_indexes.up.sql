@@ -0,0 +1,7 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+-- Trigram indexes for fuzzy matching, and pattern_ops indexes for prefixes shorter
+-- than a trigram.
+CREATE INDEX IF NOT EXISTS module_info_module_name_trgm_idx ON module_info USING GIN (lower(module_name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS module_info_module_name_prefix_idx ON module_info (lower(module_name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS user_info_full_name_trgm_idx ON user_info USING GIN (lower(fname || ' ' || sname) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS user_info_full_name_prefix_idx ON user_info (lower(fname || ' ' || sname) text_pattern_ops);